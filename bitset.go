@@ -22,6 +22,10 @@ type BitSet interface {
 	NextSet(i int) int
 	And(b1 BitSet) (bool, error)
 	Or(b1 BitSet) (bool, error)
+	// AndNot resets bits, which are set in b1.
+	AndNot(b1 BitSet) (bool, error)
+	// Inverse flips all bits of BitSet.
+	Inverse() (bool, error)
 }
 
 // readOnlyOneBitSet is a read-only one BitSet implementation.
@@ -53,7 +57,7 @@ func (b readOnlyOneBitSet) NextSet(i int) int {
 	if i >= int(b) {
 		return -1
 	}
-	return i + 1
+	return i
 }
 
 func (b readOnlyOneBitSet) And(b1 BitSet) (bool, error) {
@@ -64,6 +68,14 @@ func (b readOnlyOneBitSet) Or(b1 BitSet) (bool, error) {
 	return false, errors.New("read-only BitSet")
 }
 
+func (b readOnlyOneBitSet) AndNot(b1 BitSet) (bool, error) {
+	return false, errors.New("read-only BitSet")
+}
+
+func (b readOnlyOneBitSet) Inverse() (bool, error) {
+	return false, errors.New("read-only BitSet")
+}
+
 // readOnlyZeroBitSet is a read-only zero BitSet implementation.
 type readOnlyZeroBitSet int
 
@@ -99,6 +111,14 @@ func (b readOnlyZeroBitSet) Or(b1 BitSet) (bool, error) {
 	return false, errors.New("read-only BitSet")
 }
 
+func (b readOnlyZeroBitSet) AndNot(b1 BitSet) (bool, error) {
+	return false, errors.New("read-only BitSet")
+}
+
+func (b readOnlyZeroBitSet) Inverse() (bool, error) {
+	return false, errors.New("read-only BitSet")
+}
+
 func bitSetWordSize(n uint) uint {
 	return uint(n)>>6 + 1
 }
@@ -131,13 +151,16 @@ func newBitSetOfOnes(size int) BitSet {
 	return b
 }
 
+// grow resizes BitSet to hold size bits and resets it.
 func (b *bitSet) grow(size int) {
 	wordSize := bitSetWordSize(uint(size))
-	if uint(len(b.words)) + wordSize > uint(cap(b.words)) {
-		b.words = make([]uint64, uint(cap(b.words)) + wordSize)
+	if wordSize > uint(cap(b.words)) {
+		b.words = make([]uint64, wordSize)
+	} else {
+		b.words = b.words[0:wordSize]
+		b.Reset()
 	}
 	b.size = size
-	b.words = b.words[0:wordSize]
 }
 
 func (b *bitSet) Size() int {
@@ -197,8 +220,25 @@ func (b *bitSet) NextSet(i int) int {
 	return -1
 }
 
-func (b *bitSet) Inverse() {
-	panic("implement me")
+func (b *bitSet) Inverse() (bool, error) {
+	var notEmpty bool
+	wordSize := bitSetWordSize(uint(b.size))
+
+	for i := uint(0); i < wordSize; i++ {
+		b.words[i] = ^b.words[i]
+	}
+	// reset the tail bits past the size, so they don't count as set
+	lastWordBit := uint(b.size) & 63 // size mod 64
+	b.words[wordSize-1] &= ^(wordOfOnes << lastWordBit)
+
+	for i := uint(0); i < wordSize; i++ {
+		if b.words[i] != 0 {
+			notEmpty = true
+			break
+		}
+	}
+
+	return notEmpty, nil
 }
 
 func (b *bitSet) And(b1 BitSet) (bool, error) {
@@ -210,6 +250,11 @@ func (b *bitSet) And(b1 BitSet) (bool, error) {
 	switch b1 := b1.(type) {
 	case *bitSet:
 		words = b1.words
+	case readOnlyOneBitSet:
+		return b.NextSet(0) != -1, nil
+	case readOnlyZeroBitSet:
+		b.Reset()
+		return false, nil
 	default:
 		panic("implement me")
 	}
@@ -253,6 +298,37 @@ func (b *bitSet) Or(b1 BitSet) (bool, error) {
 	return notEmpty, nil
 }
 
+func (b *bitSet) AndNot(b1 BitSet) (bool, error) {
+	if b.Size() != b1.Size() {
+		return false, fmt.Errorf("BitSets of not equal sizes: %d, %d", b.Size(), b1.Size())
+	}
+
+	var words []uint64
+	switch b1 := b1.(type) {
+	case *bitSet:
+		words = b1.words
+	case readOnlyZeroBitSet:
+		return b.NextSet(0) != -1, nil
+	case readOnlyOneBitSet:
+		b.Reset()
+		return false, nil
+	default:
+		panic("implement me")
+	}
+
+	var notEmpty bool
+	wordSize := bitSetWordSize(uint(b.size))
+
+	for i := uint(0); i < wordSize; i++ {
+		b.words[i] &^= words[i]
+		if b.words[i] != 0 {
+			notEmpty = true
+		}
+	}
+
+	return notEmpty, nil
+}
+
 var bitSetPool = sync.Pool{}
 
 func acquireBitSet(size int) BitSet {
@@ -268,5 +344,8 @@ func acquireBitSet(size int) BitSet {
 }
 
 func releaseBitSet(b BitSet) {
-	bitSetPool.Put(b)
+	// only bitSet is reusable, read-only BitSets are cheap to create
+	if b, ok := b.(*bitSet); ok {
+		bitSetPool.Put(b)
+	}
 }
@@ -145,3 +145,89 @@ func TestBitSet_NextSet(t *testing.T) {
 		}
 	}
 }
+
+func TestBitSet_Inverse(t *testing.T) {
+	lens := []int{1, 5, 63, 64, 65, 128, 143}
+	for _, l := range lens {
+		b := newBitSet(l)
+		b.Set(0)
+
+		isAnySet, err := b.Inverse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l > 1 && !isAnySet {
+			t.Fatalf("(%d) Inverse() expected to not be empty", l)
+		}
+		if b.Test(0) {
+			t.Fatalf("(%d) expect bit 0 to be unset", l)
+		}
+		if b.Cardinality() != l-1 {
+			t.Fatalf("(%d) Cardinality() want %d, got %d", l, l-1, b.Cardinality())
+		}
+		if n := b.NextSet(l - 1); l > 1 && n != l-1 {
+			t.Fatalf("(%d) NextSet(%d) want %d, got %d", l, l-1, l-1, n)
+		}
+
+		b.Set(0)
+		if isAnySet, _ := b.Inverse(); isAnySet {
+			t.Fatalf("(%d) Inverse() of full BitSet expected to be empty", l)
+		}
+		if b.Cardinality() != 0 {
+			t.Fatalf("(%d) Cardinality() want 0, got %d", l, b.Cardinality())
+		}
+	}
+}
+
+func TestBitSet_AndNot(t *testing.T) {
+	b1, b2 := newBitSetOfOnes(70), newBitSet(70)
+
+	b2.Set(2)
+	b2.Set(66)
+
+	if _, err := b1.AndNot(b2); err != nil {
+		t.Fatal(err)
+	}
+	if !b1.Test(1) {
+		t.Error("expect bit 1 to be set")
+	}
+	if b1.Test(2) {
+		t.Error("expect bit 2 to be unset")
+	}
+	if b1.Test(66) {
+		t.Error("expect bit 66 to be unset")
+	}
+	if b1.Cardinality() != 68 {
+		t.Errorf("Cardinality() want 68, got %d", b1.Cardinality())
+	}
+
+	b1, b2 = newBitSet(5), newBitSet(10)
+	if _, err := b1.AndNot(b2); err == nil {
+		t.Fatal("AndNot() on BitSets of different sizes expected to fail")
+	}
+
+	b1, b2 = newBitSetOfOnes(5), newBitSetOfOnes(5)
+	if isAnySet, _ := b1.AndNot(b2); isAnySet {
+		t.Fatal("AndNot() of equal BitSets expected to be empty")
+	}
+
+	b1 = newBitSetOfOnes(5)
+	if isAnySet, _ := b1.AndNot(readOnlyZeroBitSet(5)); !isAnySet {
+		t.Fatal("AndNot() with zero BitSet expected to not be empty")
+	}
+	if isAnySet, _ := b1.AndNot(readOnlyOneBitSet(5)); isAnySet || b1.Cardinality() != 0 {
+		t.Fatal("AndNot() with one BitSet expected to be empty")
+	}
+}
+
+func TestBitSet_AndReadOnly(t *testing.T) {
+	b := newBitSet(5)
+	b.Set(3)
+
+	if isAnySet, _ := b.And(readOnlyOneBitSet(5)); !isAnySet || !b.Test(3) {
+		t.Fatal("And() with one BitSet expected to keep the bits")
+	}
+	if isAnySet, _ := b.And(readOnlyZeroBitSet(5)); isAnySet || b.Cardinality() != 0 {
+		t.Fatal("And() with zero BitSet expected to be empty")
+	}
+}
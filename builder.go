@@ -0,0 +1,413 @@
+package yoctodb
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// IndexOption defines how a document field is indexed.
+type IndexOption int
+
+const (
+	// field values can be used in query conditions
+	IndexFilterable IndexOption = 1 + iota
+
+	// field values can be used in query conditions and to order query results
+	IndexSortable
+
+	// field values can be used in query conditions and to order query results, and are stored
+	// in a single full segment
+	IndexFull
+)
+
+// DocumentBuilder collects fields and payload of a document to add to DBBuilder.
+type DocumentBuilder struct {
+	fields  []documentField
+	payload []byte
+}
+
+type documentField struct {
+	name string
+	val  []byte
+	opt  IndexOption
+}
+
+func NewDocumentBuilder() *DocumentBuilder {
+	return &DocumentBuilder{}
+}
+
+// WithField adds a value of the named field to the document. A filterable field may have several values
+// in a document, while a sortable or full field must have exactly one. A field may be added both
+// with IndexFilterable and IndexSortable options, to be stored in separate segments.
+func (d *DocumentBuilder) WithField(name string, val []byte, opt IndexOption) *DocumentBuilder {
+	d.fields = append(d.fields, documentField{name, val, opt})
+	return d
+}
+
+// WithPayload sets document's payload.
+func (d *DocumentBuilder) WithPayload(payload []byte) *DocumentBuilder {
+	d.payload = payload
+	return d
+}
+
+// DBBuilder builds a database in the format ReadDB understands.
+type DBBuilder struct {
+	version   int
+	noPayload bool
+	docs      []*DocumentBuilder
+}
+
+func NewDBBuilder() *DBBuilder {
+	return &DBBuilder{
+		version: DBFormatVersion,
+	}
+}
+
+// WithVersion sets the format version of the database. Both DBFormatVersion and DBFormatVersion6
+// are supported.
+func (b *DBBuilder) WithVersion(version int) *DBBuilder {
+	b.version = version
+	return b
+}
+
+// WithoutPayload makes the database store no payload, but only the number of documents.
+// Payloads of the documents are ignored.
+func (b *DBBuilder) WithoutPayload() *DBBuilder {
+	b.noPayload = true
+	return b
+}
+
+// Add adds a document to the database. Documents are indexed in the order they are added.
+func (b *DBBuilder) Add(doc *DocumentBuilder) *DBBuilder {
+	b.docs = append(b.docs, doc)
+	return b
+}
+
+// fieldBuilder collects values of a field over all documents.
+type fieldBuilder struct {
+	name string
+	opt  IndexOption
+	// docVals contains field values for each document
+	docVals [][][]byte
+}
+
+func (b *DBBuilder) fields() ([]*fieldBuilder, error) {
+	type fieldKey struct {
+		name string
+		opt  IndexOption
+	}
+	fields := make(map[fieldKey]*fieldBuilder)
+	opts := make(map[string]IndexOption)
+	for n, doc := range b.docs {
+		for _, f := range doc.fields {
+			if f.opt != IndexFilterable && f.opt != IndexSortable && f.opt != IndexFull {
+				return nil, fmt.Errorf("unknown index option %d for field %q", f.opt, f.name)
+			}
+			// a field may be indexed both filterable and sortable, which are stored in separate segments
+			if opt, ok := opts[f.name]; ok && opt != f.opt && (opt == IndexFull || f.opt == IndexFull) {
+				return nil, fmt.Errorf("field %q is indexed with different options", f.name)
+			}
+			opts[f.name] = f.opt
+
+			key := fieldKey{f.name, f.opt}
+			fb, ok := fields[key]
+			if !ok {
+				fb = &fieldBuilder{
+					name:    f.name,
+					opt:     f.opt,
+					docVals: make([][][]byte, len(b.docs)),
+				}
+				fields[key] = fb
+			}
+			fb.docVals[n] = append(fb.docVals[n], f.val)
+		}
+	}
+
+	res := make([]*fieldBuilder, 0, len(fields))
+	for _, fb := range fields {
+		if fb.opt == IndexSortable || fb.opt == IndexFull {
+			for n, vals := range fb.docVals {
+				if len(vals) != 1 {
+					return nil, fmt.Errorf("document %d must have exactly one value of sortable field %q, got %d",
+						n, fb.name, len(vals))
+				}
+			}
+		}
+		res = append(res, fb)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].name != res[j].name {
+			return res[i].name < res[j].name
+		}
+		return res[i].opt < res[j].opt
+	})
+	return res, nil
+}
+
+// WriteTo writes the database to w.
+func (b *DBBuilder) WriteTo(w io.Writer) (int64, error) {
+	if b.version != DBFormatVersion && b.version != DBFormatVersion6 {
+		return 0, fmt.Errorf("version format %d is not supported", b.version)
+	}
+
+	fields, err := b.fields()
+	if err != nil {
+		return 0, err
+	}
+
+	cw := &countingWriter{w: w}
+
+	header := append([]byte(nil), dbFormatMagic...)
+	header = binary.BigEndian.AppendUint32(header, uint32(b.version))
+	if b.version == DBFormatVersion6 {
+		header = binary.BigEndian.AppendUint32(header, uint32(len(b.docs)))
+	}
+	if _, err := cw.Write(header); err != nil {
+		return cw.n, err
+	}
+
+	// digest is calculated over all segments
+	digest := md5.New()
+	sw := io.MultiWriter(cw, digest)
+
+	payloadType, payload := b.payloadSegment()
+	if err := writeSegment(sw, payloadType, payload); err != nil {
+		return cw.n, err
+	}
+	for _, fb := range fields {
+		typ, data := fb.segment()
+		if err := writeSegment(sw, typ, data); err != nil {
+			return cw.n, err
+		}
+	}
+
+	_, err = cw.Write(digest.Sum(nil))
+	return cw.n, err
+}
+
+func (b *DBBuilder) payloadSegment() (uint32, []byte) {
+	if b.noPayload {
+		return PayloadNone, binary.BigEndian.AppendUint32(nil, uint32(len(b.docs)))
+	}
+
+	payloads := make([][]byte, len(b.docs))
+	for n, doc := range b.docs {
+		payloads[n] = doc.payload
+	}
+	return PayloadFull, appendChunk(nil, encodeVarLenSortedSet(payloads))
+}
+
+func (fb *fieldBuilder) segment() (uint32, []byte) {
+	// collect sorted unique values of the field
+	uniq := make(map[string]struct{})
+	for _, vals := range fb.docVals {
+		for _, val := range vals {
+			uniq[string(val)] = struct{}{}
+		}
+	}
+	vals := make([][]byte, 0, len(uniq))
+	for val := range uniq {
+		vals = append(vals, []byte(val))
+	}
+	sort.Slice(vals, func(i, j int) bool {
+		return bytes.Compare(vals[i], vals[j]) < 0
+	})
+
+	valIndex := func(val []byte) int {
+		return sort.Search(len(vals), func(i int) bool {
+			return bytes.Compare(vals[i], val) >= 0
+		})
+	}
+
+	valToDocs := make([][]int, len(vals))
+	for n, docVals := range fb.docVals {
+		for _, val := range docVals {
+			i := valIndex(val)
+			// a document may have the same value several times
+			if docs := valToDocs[i]; len(docs) > 0 && docs[len(docs)-1] == n {
+				continue
+			}
+			valToDocs[i] = append(valToDocs[i], n)
+		}
+	}
+
+	fixedLen := fixedElemSize(vals)
+
+	var typ uint32
+	switch {
+	case fb.opt == IndexFilterable && fixedLen > 0:
+		typ = FixedLenFilterableIndex
+	case fb.opt == IndexFilterable:
+		typ = VarLenFilterableIndex
+	case fb.opt == IndexSortable && fixedLen > 0:
+		typ = FixedLenSortableIndex
+	case fb.opt == IndexSortable:
+		typ = VarLenSortableIndex
+	case fixedLen > 0:
+		typ = FixedLenFullIndexSegment
+	default:
+		typ = VarLenFullIndexSegment
+	}
+
+	data := appendBytes(nil, []byte(fb.name))
+	if fixedLen > 0 {
+		data = appendChunk(data, encodeFixedLenSortedSet(vals, fixedLen))
+	} else {
+		data = appendChunk(data, encodeVarLenSortedSet(vals))
+	}
+	data = appendChunk(data, encodeIndexToIndexMultiMap(valToDocs, len(fb.docVals)))
+
+	if fb.opt == IndexSortable || fb.opt == IndexFull {
+		docToVals := make([]int, len(fb.docVals))
+		for n, docVals := range fb.docVals {
+			docToVals[n] = valIndex(docVals[0])
+		}
+		data = append(data, encodeIntIndexToIndexMap(docToVals)...)
+	}
+
+	return typ, data
+}
+
+// fixedElemSize returns the size of values if all of them are of the same non-zero size, and zero otherwise.
+func fixedElemSize(vals [][]byte) int {
+	if len(vals) == 0 {
+		return 0
+	}
+	size := len(vals[0])
+	for _, val := range vals[1:] {
+		if len(val) != size {
+			return 0
+		}
+	}
+	return size
+}
+
+func encodeFixedLenSortedSet(vals [][]byte, elemSize int) []byte {
+	data := make([]byte, 0, 8+len(vals)*elemSize)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(vals)))
+	data = binary.BigEndian.AppendUint32(data, uint32(elemSize))
+	for _, val := range vals {
+		data = append(data, val...)
+	}
+	return data
+}
+
+func encodeVarLenSortedSet(vals [][]byte) []byte {
+	data := make([]byte, 0, 4+(len(vals)+1)<<3)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(vals)))
+
+	var offset uint64
+	data = binary.BigEndian.AppendUint64(data, offset)
+	for _, val := range vals {
+		offset += uint64(len(val))
+		data = binary.BigEndian.AppendUint64(data, offset)
+	}
+	for _, val := range vals {
+		data = append(data, val...)
+	}
+	return data
+}
+
+// encodeIndexToIndexMultiMap encodes valToDocs either as BitSets or lists of document indexes,
+// whatever is smaller. Lists are smaller for high-cardinality fields.
+func encodeIndexToIndexMultiMap(valToDocs [][]int, docCount int) []byte {
+	bitSetSize := len(valToDocs) * int(bitSetWordSize(uint(docCount))) << 3
+	listSize := len(valToDocs) * 12
+	for _, docs := range valToDocs {
+		listSize += len(docs) << 2
+	}
+	if listSize < bitSetSize {
+		return encodeListIndexToIndexMultiMap(valToDocs)
+	}
+	return encodeBitSetIndexToIndexMultiMap(valToDocs, docCount)
+}
+
+func encodeListIndexToIndexMultiMap(valToDocs [][]int) []byte {
+	data := make([]byte, 0, 8+len(valToDocs)<<3)
+	data = binary.BigEndian.AppendUint32(data, multimapListBased)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(valToDocs)))
+
+	var offset uint64
+	for _, docs := range valToDocs {
+		data = binary.BigEndian.AppendUint64(data, offset)
+		offset += uint64(4 + len(docs)<<2)
+	}
+	for _, docs := range valToDocs {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(docs)))
+		for _, n := range docs {
+			data = binary.BigEndian.AppendUint32(data, uint32(n))
+		}
+	}
+	return data
+}
+
+func encodeBitSetIndexToIndexMultiMap(valToDocs [][]int, docCount int) []byte {
+	wordSize := int(bitSetWordSize(uint(docCount)))
+
+	data := make([]byte, 0, 12+len(valToDocs)*wordSize<<3)
+	data = binary.BigEndian.AppendUint32(data, multimapBitSetBased)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(valToDocs)))
+	data = binary.BigEndian.AppendUint32(data, uint32(wordSize))
+
+	words := make([]uint64, wordSize)
+	for _, docs := range valToDocs {
+		for i := range words {
+			words[i] = 0
+		}
+		for _, n := range docs {
+			words[n>>6] |= 1 << (uint(n) & 63)
+		}
+		for _, w := range words {
+			data = binary.BigEndian.AppendUint64(data, w)
+		}
+	}
+	return data
+}
+
+func encodeIntIndexToIndexMap(vals []int) []byte {
+	data := make([]byte, 0, 4+len(vals)<<2)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(vals)))
+	for _, v := range vals {
+		data = binary.BigEndian.AppendUint32(data, uint32(v))
+	}
+	return data
+}
+
+// appendChunk appends data prefixed with its length.
+func appendChunk(b []byte, data []byte) []byte {
+	b = binary.BigEndian.AppendUint64(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// appendBytes appends data prefixed with its length, the way bytesDecoder.bytes expects.
+func appendBytes(b []byte, data []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+// writeSegment writes segment's header (size + type) followed by segment's data.
+func writeSegment(w io.Writer, typ uint32, data []byte) error {
+	var header [12]byte
+	binary.BigEndian.PutUint64(header[0:], uint64(len(data)))
+	binary.BigEndian.PutUint32(header[8:], typ)
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,249 @@
+package yoctodb_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/narqo/yoctodb"
+)
+
+type testCar struct {
+	Brand string
+	Color string
+	Price string
+	Tags  []string
+}
+
+var testCars = []testCar{
+	{"audi", "FF0000", "300", []string{"sedan"}},
+	{"bmw", "00FF00", "200", []string{"coupe", "sport"}},
+	{"audi", "FF0000", "100", nil},
+	{"bmw", "0000FF", "300", []string{"sedan", "sport"}},
+	{"ford", "FF0000", "200", []string{"pickup"}},
+}
+
+func buildTestDB(t *testing.T, b *yoctodb.DBBuilder) *yoctodb.DB {
+	t.Helper()
+
+	var buf bytes.Buffer
+	n, err := b.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("WriteTo() want %d bytes written, got %d", buf.Len(), n)
+	}
+
+	db, err := yoctodb.ReadVerifyDB(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newTestCarsDBBuilder() *yoctodb.DBBuilder {
+	b := yoctodb.NewDBBuilder()
+	for _, car := range testCars {
+		doc := yoctodb.NewDocumentBuilder().
+			WithField("brand", []byte(car.Brand), yoctodb.IndexFilterable).
+			WithField("color", []byte(car.Color), yoctodb.IndexFilterable).
+			WithField("price", []byte(car.Price), yoctodb.IndexSortable).
+			WithPayload([]byte(car.Brand + ":" + car.Price))
+		for _, tag := range car.Tags {
+			doc.WithField("tag", []byte(tag), yoctodb.IndexFilterable)
+		}
+		b.Add(doc)
+	}
+	return b
+}
+
+type payloadsProcessor []string
+
+func (p *payloadsProcessor) Process(d int, rawData []byte) error {
+	*p = append(*p, string(rawData))
+	return nil
+}
+
+func queryPayloads(t *testing.T, db *yoctodb.DB, q yoctodb.Query) []string {
+	t.Helper()
+
+	docs, err := db.Query(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer docs.Close()
+
+	var payloads payloadsProcessor
+	for docs.Next() {
+		if err := docs.Scan(&payloads); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return payloads
+}
+
+func TestDBBuilder(t *testing.T) {
+	db := buildTestDB(t, newTestCarsDBBuilder())
+
+	if n := db.DocumentsCount(); n != len(testCars) {
+		t.Fatalf("DocumentsCount() want %d, got %d", len(testCars), n)
+	}
+	if db.Filter("brand") == nil || db.Filter("color") == nil || db.Filter("tag") == nil {
+		t.Fatal("expect filterable indexes to be read")
+	}
+	if db.Sorter("price") == nil {
+		t.Fatal("expect sortable index to be read")
+	}
+
+	tests := []struct {
+		Query yoctodb.Query
+		Want  []string
+	}{
+		{
+			&yoctodb.Select{},
+			[]string{"audi:300", "bmw:200", "audi:100", "bmw:300", "ford:200"},
+		},
+		{
+			&yoctodb.Select{Where: yoctodb.Eq("color", []byte("FF0000"))},
+			[]string{"audi:300", "audi:100", "ford:200"},
+		},
+		{
+			&yoctodb.Select{Where: yoctodb.Eq("tag", []byte("sport"))},
+			[]string{"bmw:200", "bmw:300"},
+		},
+		{
+			&yoctodb.Select{
+				Where:   yoctodb.Or(yoctodb.Eq("brand", []byte("audi")), yoctodb.Eq("tag", []byte("pickup"))),
+				OrderBy: yoctodb.Desc("price"),
+			},
+			[]string{"audi:300", "ford:200", "audi:100"},
+		},
+		{
+			&yoctodb.Select{
+				Where:   yoctodb.Lte("price", []byte("200")),
+				OrderBy: yoctodb.Asc("price"),
+			},
+			[]string{"audi:100", "bmw:200", "ford:200"},
+		},
+	}
+
+	for n, tc := range tests {
+		got := queryPayloads(t, db, tc.Query)
+		if !reflect.DeepEqual(got, tc.Want) {
+			t.Errorf("case %d: want %v, got %v", n, tc.Want, got)
+		}
+	}
+}
+
+func TestDBBuilder_Empty(t *testing.T) {
+	db := buildTestDB(t, yoctodb.NewDBBuilder())
+
+	if n := db.DocumentsCount(); n != 0 {
+		t.Fatalf("DocumentsCount() want 0, got %d", n)
+	}
+}
+
+func TestDBBuilder_Errors(t *testing.T) {
+	tests := []*yoctodb.DBBuilder{
+		yoctodb.NewDBBuilder().
+			Add(yoctodb.NewDocumentBuilder().WithField("price", []byte("1"), yoctodb.IndexSortable)).
+			Add(yoctodb.NewDocumentBuilder()),
+		yoctodb.NewDBBuilder().
+			Add(yoctodb.NewDocumentBuilder().
+				WithField("price", []byte("1"), yoctodb.IndexSortable).
+				WithField("price", []byte("2"), yoctodb.IndexSortable)),
+		yoctodb.NewDBBuilder().
+			Add(yoctodb.NewDocumentBuilder().WithField("price", []byte("1"), yoctodb.IndexSortable)).
+			Add(yoctodb.NewDocumentBuilder().WithField("price", []byte("2"), yoctodb.IndexFilterable)),
+		yoctodb.NewDBBuilder().
+			Add(yoctodb.NewDocumentBuilder().
+				WithField("price", []byte("1"), yoctodb.IndexFull).
+				WithField("price", []byte("1"), yoctodb.IndexFilterable)),
+		yoctodb.NewDBBuilder().
+			Add(yoctodb.NewDocumentBuilder().WithField("price", []byte("1"), 0)),
+	}
+
+	for n, b := range tests {
+		var buf bytes.Buffer
+		if _, err := b.WriteTo(&buf); err == nil {
+			t.Errorf("case %d: WriteTo() expected to fail", n)
+		}
+	}
+}
+
+func TestDBBuilder_WithoutPayload(t *testing.T) {
+	db := buildTestDB(t, newTestCarsDBBuilder().WithoutPayload())
+
+	if n := db.DocumentsCount(); n != len(testCars) {
+		t.Fatalf("DocumentsCount() want %d, got %d", len(testCars), n)
+	}
+
+	ctx := context.Background()
+	n, err := db.Count(ctx, &yoctodb.Select{Where: yoctodb.Eq("brand", []byte("audi"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("Count() want 2, got %d", n)
+	}
+
+	if _, err := db.Document(0); err != yoctodb.ErrNoPayload {
+		t.Fatalf("Document() want %v, got %v", yoctodb.ErrNoPayload, err)
+	}
+
+	docs, err := db.Query(ctx, &yoctodb.Select{Where: yoctodb.Eq("color", []byte("FF0000"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer docs.Close()
+
+	var ids []int
+	for docs.Next() {
+		err := docs.Scan(yoctodb.DocumentProcessorFunc(func(d int, rawData []byte) error {
+			if rawData != nil {
+				t.Errorf("document %d: want nil payload, got %q", d, rawData)
+			}
+			ids = append(ids, d)
+			return nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := docs.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{0, 2, 4}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("want documents %v, got %v", want, ids)
+	}
+	if _, err := db.Document(len(testCars)); err == nil || err == yoctodb.ErrNoPayload {
+		t.Fatalf("Document() out of bounds want error, got %v", err)
+	}
+}
+
+func TestDBBuilder_IndexFull(t *testing.T) {
+	b := yoctodb.NewDBBuilder()
+	for _, car := range testCars {
+		b.Add(yoctodb.NewDocumentBuilder().
+			WithField("brand", []byte(car.Brand), yoctodb.IndexFull).
+			WithField("price", []byte(car.Price), yoctodb.IndexFull).
+			WithPayload([]byte(car.Brand + ":" + car.Price)))
+	}
+	db := buildTestDB(t, b)
+
+	for _, name := range []string{"brand", "price"} {
+		if db.Filter(name) == nil || db.Sorter(name) == nil {
+			t.Fatalf("expect full index of field %q to be both filterable and sortable", name)
+		}
+	}
+
+	got := queryPayloads(t, db, &yoctodb.Select{
+		Where:   yoctodb.In("brand", []byte("audi"), []byte("ford")),
+		OrderBy: yoctodb.OrderBy(yoctodb.Sort{"price", yoctodb.Descending}, yoctodb.Sort{"brand", yoctodb.Ascending}),
+	})
+	if want := []string{"audi:300", "ford:200", "audi:100"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
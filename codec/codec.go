@@ -0,0 +1,201 @@
+// Package codec implements order-preserving encodings of typed values.
+//
+// Values are encoded to big-endian byte strings, which compare with bytes.Compare in the same order
+// as the original values. This is the order in which SortedSet of yoctodb stores values, so the encoded
+// values can be used both for filtering by ranges and for sorting.
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	signBit64 = 1 << 63
+	signBit32 = 1 << 31
+)
+
+// EncodeUint64 encodes v as 8 bytes.
+func EncodeUint64(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
+
+// DecodeUint64 decodes a value encoded with EncodeUint64.
+func DecodeUint64(b []byte) (uint64, error) {
+	if err := checkLen(b, 8); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// EncodeUint32 encodes v as 4 bytes.
+func EncodeUint32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+// DecodeUint32 decodes a value encoded with EncodeUint32.
+func DecodeUint32(b []byte) (uint32, error) {
+	if err := checkLen(b, 4); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+// EncodeInt64 encodes v as 8 bytes. The sign bit is flipped, so negative values go first.
+func EncodeInt64(v int64) []byte {
+	return EncodeUint64(uint64(v) ^ signBit64)
+}
+
+// DecodeInt64 decodes a value encoded with EncodeInt64.
+func DecodeInt64(b []byte) (int64, error) {
+	v, err := DecodeUint64(b)
+	if err != nil {
+		return 0, err
+	}
+	return int64(v ^ signBit64), nil
+}
+
+// EncodeInt32 encodes v as 4 bytes. The sign bit is flipped, so negative values go first.
+func EncodeInt32(v int32) []byte {
+	return EncodeUint32(uint32(v) ^ signBit32)
+}
+
+// DecodeInt32 decodes a value encoded with EncodeInt32.
+func DecodeInt32(b []byte) (int32, error) {
+	v, err := DecodeUint32(b)
+	if err != nil {
+		return 0, err
+	}
+	return int32(v ^ signBit32), nil
+}
+
+// EncodeFloat64 encodes v as 8 bytes. Positive values have the sign bit set, while all bits of negative
+// values are flipped, so that larger negative values go first. Negative zero is encoded as zero.
+func EncodeFloat64(v float64) []byte {
+	if v == 0 {
+		v = 0
+	}
+	bits := math.Float64bits(v)
+	if bits&signBit64 != 0 {
+		bits = ^bits
+	} else {
+		bits |= signBit64
+	}
+	return EncodeUint64(bits)
+}
+
+// DecodeFloat64 decodes a value encoded with EncodeFloat64.
+func DecodeFloat64(b []byte) (float64, error) {
+	bits, err := DecodeUint64(b)
+	if err != nil {
+		return 0, err
+	}
+	if bits&signBit64 != 0 {
+		bits &^= signBit64
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits), nil
+}
+
+// EncodeFloat32 encodes v as 4 bytes the same way EncodeFloat64 does.
+func EncodeFloat32(v float32) []byte {
+	if v == 0 {
+		v = 0
+	}
+	bits := math.Float32bits(v)
+	if bits&signBit32 != 0 {
+		bits = ^bits
+	} else {
+		bits |= signBit32
+	}
+	return EncodeUint32(bits)
+}
+
+// DecodeFloat32 decodes a value encoded with EncodeFloat32.
+func DecodeFloat32(b []byte) (float32, error) {
+	bits, err := DecodeUint32(b)
+	if err != nil {
+		return 0, err
+	}
+	if bits&signBit32 != 0 {
+		bits &^= signBit32
+	} else {
+		bits = ^bits
+	}
+	return math.Float32frombits(bits), nil
+}
+
+// EncodeBool encodes v as a single byte, false goes first.
+func EncodeBool(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// DecodeBool decodes a value encoded with EncodeBool.
+func DecodeBool(b []byte) (bool, error) {
+	if err := checkLen(b, 1); err != nil {
+		return false, err
+	}
+	switch b[0] {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	}
+	return false, fmt.Errorf("codec: invalid bool value %d", b[0])
+}
+
+var (
+	minTime = time.Unix(0, math.MinInt64)
+	maxTime = time.Unix(0, math.MaxInt64)
+)
+
+// EncodeTime encodes t as the number of nanoseconds since the Unix epoch, the same way EncodeInt64 does.
+// Only the times between years 1678 and 2262 can be represented, the times outside are clamped
+// to the nearest representable one to keep the order.
+func EncodeTime(t time.Time) []byte {
+	switch {
+	case t.Before(minTime):
+		t = minTime
+	case t.After(maxTime):
+		t = maxTime
+	}
+	return EncodeInt64(t.UnixNano())
+}
+
+// DecodeTime decodes a value encoded with EncodeTime. The time is returned in UTC.
+func DecodeTime(b []byte) (time.Time, error) {
+	v, err := DecodeInt64(b)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, v).UTC(), nil
+}
+
+// EncodeUUID encodes UUID as its 16 bytes.
+func EncodeUUID(v [16]byte) []byte {
+	b := make([]byte, 16)
+	copy(b, v[:])
+	return b
+}
+
+// DecodeUUID decodes a value encoded with EncodeUUID.
+func DecodeUUID(b []byte) (v [16]byte, err error) {
+	if err := checkLen(b, 16); err != nil {
+		return v, err
+	}
+	copy(v[:], b)
+	return v, nil
+}
+
+func checkLen(b []byte, n int) error {
+	if len(b) != n {
+		return fmt.Errorf("codec: invalid value length %d, want %d", len(b), n)
+	}
+	return nil
+}
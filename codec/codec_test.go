@@ -0,0 +1,183 @@
+package codec
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+// checkOrder checks that encoded values are in strictly increasing order.
+func checkOrder(t *testing.T, name string, encoded [][]byte) {
+	t.Helper()
+	for i := 1; i < len(encoded); i++ {
+		if bytes.Compare(encoded[i-1], encoded[i]) >= 0 {
+			t.Errorf("%s: encoded value %d (%x) expected to go before %d (%x)",
+				name, i-1, encoded[i-1], i, encoded[i])
+		}
+	}
+}
+
+func TestInt64(t *testing.T) {
+	vals := []int64{math.MinInt64, -1 << 40, -2, -1, 0, 1, 2, 1 << 40, math.MaxInt64}
+	var encoded [][]byte
+	for _, v := range vals {
+		b := EncodeInt64(v)
+		got, err := DecodeInt64(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v {
+			t.Errorf("DecodeInt64(EncodeInt64(%d)) = %d", v, got)
+		}
+		encoded = append(encoded, b)
+	}
+	checkOrder(t, "int64", encoded)
+
+	if v, err := DecodeInt64([]byte{1, 2, 3}); err == nil || v != 0 {
+		t.Errorf("DecodeInt64() of short value want 0 and error, got %d, %v", v, err)
+	}
+}
+
+func TestInt32(t *testing.T) {
+	vals := []int32{math.MinInt32, -2, -1, 0, 1, math.MaxInt32}
+	var encoded [][]byte
+	for _, v := range vals {
+		b := EncodeInt32(v)
+		got, err := DecodeInt32(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v {
+			t.Errorf("DecodeInt32(EncodeInt32(%d)) = %d", v, got)
+		}
+		encoded = append(encoded, b)
+	}
+	checkOrder(t, "int32", encoded)
+
+	if v, err := DecodeInt32([]byte{1, 2, 3}); err == nil || v != 0 {
+		t.Errorf("DecodeInt32() of short value want 0 and error, got %d, %v", v, err)
+	}
+}
+
+func TestUint64(t *testing.T) {
+	vals := []uint64{0, 1, 255, 256, 1 << 40, math.MaxUint64}
+	var encoded [][]byte
+	for _, v := range vals {
+		b := EncodeUint64(v)
+		got, err := DecodeUint64(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v {
+			t.Errorf("DecodeUint64(EncodeUint64(%d)) = %d", v, got)
+		}
+		encoded = append(encoded, b)
+	}
+	checkOrder(t, "uint64", encoded)
+}
+
+func TestFloat64(t *testing.T) {
+	vals := []float64{math.Inf(-1), -math.MaxFloat64, -1.5, -1, -math.SmallestNonzeroFloat64, 0,
+		math.SmallestNonzeroFloat64, 1, 1.5, math.MaxFloat64, math.Inf(1)}
+	var encoded [][]byte
+	for _, v := range vals {
+		b := EncodeFloat64(v)
+		got, err := DecodeFloat64(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v {
+			t.Errorf("DecodeFloat64(EncodeFloat64(%v)) = %v", v, got)
+		}
+		encoded = append(encoded, b)
+	}
+	checkOrder(t, "float64", encoded)
+
+	if !bytes.Equal(EncodeFloat64(math.Copysign(0, -1)), EncodeFloat64(0)) {
+		t.Error("negative zero expected to be encoded as zero")
+	}
+}
+
+func TestFloat32(t *testing.T) {
+	vals := []float32{float32(math.Inf(-1)), -math.MaxFloat32, -1, 0, 1, math.MaxFloat32, float32(math.Inf(1))}
+	var encoded [][]byte
+	for _, v := range vals {
+		b := EncodeFloat32(v)
+		got, err := DecodeFloat32(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v {
+			t.Errorf("DecodeFloat32(EncodeFloat32(%v)) = %v", v, got)
+		}
+		encoded = append(encoded, b)
+	}
+	checkOrder(t, "float32", encoded)
+}
+
+func TestBool(t *testing.T) {
+	checkOrder(t, "bool", [][]byte{EncodeBool(false), EncodeBool(true)})
+
+	for _, v := range []bool{false, true} {
+		got, err := DecodeBool(EncodeBool(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v {
+			t.Errorf("DecodeBool(EncodeBool(%v)) = %v", v, got)
+		}
+	}
+	if _, err := DecodeBool([]byte{2}); err == nil {
+		t.Error("DecodeBool() of invalid value expected to fail")
+	}
+}
+
+func TestTime(t *testing.T) {
+	vals := []time.Time{
+		time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(1969, 12, 31, 23, 59, 59, 999, time.UTC),
+		time.Unix(0, 0).UTC(),
+		time.Date(2020, 2, 29, 12, 30, 0, 1, time.UTC),
+	}
+	var encoded [][]byte
+	for _, v := range vals {
+		b := EncodeTime(v)
+		got, err := DecodeTime(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(v) {
+			t.Errorf("DecodeTime(EncodeTime(%v)) = %v", v, got)
+		}
+		encoded = append(encoded, b)
+	}
+	checkOrder(t, "time", encoded)
+
+	// times out of the range are clamped, but keep the order
+	clamped := [][]byte{
+		EncodeTime(time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC)),
+		EncodeTime(time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC)),
+		EncodeTime(vals[0]),
+		EncodeTime(time.Date(2500, 1, 1, 0, 0, 0, 0, time.UTC)),
+		EncodeTime(time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+	if !bytes.Equal(clamped[0], clamped[1]) || !bytes.Equal(clamped[3], clamped[4]) {
+		t.Error("times out of the range expected to be clamped")
+	}
+	checkOrder(t, "clamped time", clamped[1:4])
+}
+
+func TestUUID(t *testing.T) {
+	v := [16]byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+	got, err := DecodeUUID(EncodeUUID(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != v {
+		t.Errorf("DecodeUUID(EncodeUUID(%x)) = %x", v, got)
+	}
+	if _, err := DecodeUUID(v[:8]); err == nil {
+		t.Error("DecodeUUID() of short value expected to fail")
+	}
+}
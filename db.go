@@ -5,33 +5,88 @@ import (
 )
 
 type DB struct {
+	version int
 	filters map[string]*FilterableIndex
 	sorters map[string]*SortableIndex
-	payload *Payload
+	payload payloadSegment
+
+	// lazy holds segments, which are not read yet
+	lazy *lazySegments
+
+	// closer releases resources the DB was opened with
+	closer func() error
+}
+
+// Close releases resources used by the database. Neither the DB nor results of its queries
+// can be used after.
+func (db *DB) Close() error {
+	if db.closer == nil {
+		return nil
+	}
+	closer := db.closer
+	db.closer = nil
+	return closer()
 }
 
+// Version returns the format version of the database.
+func (db *DB) Version() int {
+	return db.version
+}
+
+// Filter returns FilterableIndex of the field or nil, if there is no such index or it couldn't be read.
 func (db *DB) Filter(name string) *FilterableIndex {
-	return db.filters[name]
+	f, _ := db.filter(name)
+	return f
 }
 
+// Sorter returns SortableIndex of the field or nil, if there is no such index or it couldn't be read.
 func (db *DB) Sorter(name string) *SortableIndex {
-	return db.sorters[name]
+	s, _ := db.sorter(name)
+	return s
+}
+
+func (db *DB) filter(name string) (*FilterableIndex, error) {
+	if f := db.filters[name]; f != nil || db.lazy == nil {
+		return f, nil
+	}
+	return db.lazy.filter(name)
+}
+
+func (db *DB) sorter(name string) (*SortableIndex, error) {
+	if s := db.sorters[name]; s != nil || db.lazy == nil {
+		return s, nil
+	}
+	return db.lazy.sorter(name)
+}
+
+// filterable returns an index of the field, which can be used for filtering.
+// Both FilterableIndex and SortableIndex of the field can.
+func (db *DB) filterable(name string) (*FilterableIndex, error) {
+	f, err := db.filter(name)
+	if f != nil || err != nil {
+		return f, err
+	}
+	s, err := db.sorter(name)
+	if s != nil {
+		return &s.FilterableIndex, nil
+	}
+	return nil, err
 }
 
 func (db *DB) Document(i int) ([]byte, error) {
-	return db.payload.Get(i)
+	return db.payload.document(i)
 }
 
 func (db *DB) DocumentsCount() int {
-	return db.payload.Size()
+	return db.payload.documentsCount()
 }
 
 func (db *DB) Query(ctx context.Context, q Query) (*Documents, error) {
-	return q.exec(db)
+	return q.exec(ctx, db)
 }
 
 func (db *DB) Count(ctx context.Context, q Query) (int, error) {
-	bs, err := q.filteredUnlimited(db)
+	bs, err := q.filteredUnlimited(ctx, db)
 	if err != nil {
 		return 0, err
 	}
@@ -48,10 +103,11 @@ func (db *DB) Count(ctx context.Context, q Query) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	count := uint(bs.Cardinality()) - offset
-	if count < 0 {
-		count = 0
+	count := uint(bs.Cardinality())
+	if count <= offset {
+		return 0, nil
 	}
+	count -= offset
 	if limit > 0 && count > limit {
 		return int(limit), nil
 	}
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"context"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/narqo/yoctodb"
@@ -36,3 +39,41 @@ func BenchmarkDB_Count(b *testing.B) {
 		}
 	}
 }
+
+func TestOpenDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.yocto")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTestCarsDBBuilder().WriteTo(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := yoctodb.OpenVerifyDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := queryPayloads(t, db, &yoctodb.Select{
+		Where:   yoctodb.Eq("color", []byte("FF0000")),
+		OrderBy: yoctodb.Asc("price"),
+	})
+	if want := []string{"audi:100", "ford:200", "audi:300"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("second Close() want no error, got %v", err)
+	}
+
+	if _, err := yoctodb.OpenDB(filepath.Join(t.TempDir(), "missing.yocto")); err == nil {
+		t.Fatal("OpenDB() of missing file expected to fail")
+	}
+}
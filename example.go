@@ -48,8 +48,9 @@ func main() {
 	sorted := &yoctodb.Select{
 		Where: yoctodb.And(
 			yoctodb.Eq("wheel_key", []byte("LEFT")),
-			//yoctodb.Gte{"id": 1},
-			//yoctodb.Lte{"id": 1},
+			// ids are strings like "autoru-xxxxxxxx", so the bounds are compared as bytes
+			yoctodb.Gte("id", []byte("autoru-1")),
+			yoctodb.Lt("id", []byte("autoru-2")),
 		),
 		Offset:  1,
 		OrderBy: yoctodb.Desc("mark_model_sort"),
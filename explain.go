@@ -0,0 +1,313 @@
+package yoctodb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Plan describes how a query was executed.
+type Plan struct {
+	// Root is the plan of the query condition. It is nil, if the query has no condition.
+	Root *PlanNode
+	// Matched is the number of documents matched by the query condition, regardless of limit and offset.
+	Matched int
+	// Scorer describes the order, in which the matching documents are returned.
+	Scorer string
+}
+
+// PlanNode describes the evaluation of a single condition.
+type PlanNode struct {
+	// Seq is the order, in which the node was evaluated, starting from 1. It is zero, if the node
+	// wasn't evaluated.
+	Seq int
+	// Op is the name of the condition, e.g. Eq, In or And.
+	Op string
+	// Field is the name of the field the condition filters by.
+	Field string
+	// Values are the values the condition compares with. A missing bound of a range is nil.
+	Values [][]byte
+	// Index is the kind of the index used for the field: "filterable" or "sortable".
+	// It is empty, if the field has no index.
+	Index string
+	// IndexValues is the number of distinct values in the index.
+	IndexValues int
+	// Matched is the number of documents matched by the node.
+	Matched int
+	// EarlyExit reports that And node stopped before evaluating all its children, because
+	// no documents could match.
+	EarlyExit bool
+
+	Children []*PlanNode
+}
+
+// String returns a human readable representation of the plan.
+func (p *Plan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "matched=%d scorer=%s\n", p.Matched, p.Scorer)
+	if p.Root != nil {
+		p.Root.format(&b, 1)
+	}
+	return b.String()
+}
+
+func (n *PlanNode) format(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	if n.Seq > 0 {
+		fmt.Fprintf(b, "#%d ", n.Seq)
+	} else {
+		b.WriteString("#- ")
+	}
+	b.WriteString(n.Op)
+	if n.Field != "" {
+		fmt.Fprintf(b, " %s", n.Field)
+	}
+	if n.Values != nil {
+		vals := make([]string, len(n.Values))
+		for i, v := range n.Values {
+			if v == nil {
+				vals[i] = "-"
+			} else {
+				vals[i] = fmt.Sprintf("%q", v)
+			}
+		}
+		fmt.Fprintf(b, " [%s]", strings.Join(vals, " "))
+	}
+	if n.Index != "" {
+		fmt.Fprintf(b, " index=%s/%d", n.Index, n.IndexValues)
+	}
+	fmt.Fprintf(b, " matched=%d", n.Matched)
+	if n.EarlyExit {
+		b.WriteString(" early-exit")
+	}
+	b.WriteByte('\n')
+
+	for _, child := range n.Children {
+		child.format(b, depth+1)
+	}
+}
+
+// Explain executes the query and reports how its conditions were evaluated.
+//
+// Every condition node is evaluated into a separate bitset to count the documents it matches,
+// so Explain is slower than Query.
+func (db *DB) Explain(ctx context.Context, q Query) (*Plan, error) {
+	return q.explain(ctx, db)
+}
+
+func (s *Select) explain(ctx context.Context, db *DB) (*Plan, error) {
+	plan := &Plan{}
+
+	traced := *s
+	if s.Where != nil {
+		tracer := &planTracer{}
+		var err error
+		traced.Where, plan.Root, err = tracer.trace(db, s.Where)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	docs, err := traced.exec(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer docs.Close()
+
+	if plan.Root != nil {
+		plan.Root.finish(db.DocumentsCount())
+		plan.Matched = plan.Root.Matched
+	} else {
+		plan.Matched = db.DocumentsCount()
+	}
+	plan.Scorer = describeScorer(docs.scorer)
+
+	return plan, nil
+}
+
+// finish fills in the details of the nodes, which are only known after the evaluation.
+func (n *PlanNode) finish(size int) {
+	for _, child := range n.Children {
+		child.finish(size)
+		if n.Op == "And" && n.Seq > 0 && child.Seq == 0 {
+			n.EarlyExit = true
+		}
+	}
+	// Not is evaluated as part of its child; it matches every document the child doesn't
+	if n.Op == "Not" && n.Seq > 0 {
+		n.Matched = size - n.Children[0].Matched
+	}
+}
+
+func describeScorer(s Scorer) string {
+	switch s := s.(type) {
+	case *idScorer:
+		return "id"
+	case *sortingScorer:
+		fields := make([]string, len(s.fields))
+		for i, f := range s.fields {
+			order := "asc"
+			if f.Order == Descending {
+				order = "desc"
+			}
+			fields[i] = f.Name + " " + order
+		}
+		desc := "sort(" + strings.Join(fields, ", ") + ")"
+		if s.limit > 0 {
+			desc += fmt.Sprintf(" top=%d", s.limit)
+		}
+		return desc
+	default:
+		return fmt.Sprintf("%T", s)
+	}
+}
+
+// planTracer builds a copy of the condition tree, which records its evaluation into plan nodes.
+type planTracer struct {
+	seq int
+}
+
+func (t *planTracer) trace(db *DB, c Condition) (Condition, *PlanNode, error) {
+	node := &PlanNode{}
+	switch c := c.(type) {
+	case *andCondition:
+		node.Op = "And"
+		traced := make(andCondition, len(*c))
+		for i, child := range *c {
+			tc, childNode, err := t.trace(db, child)
+			if err != nil {
+				return nil, nil, err
+			}
+			traced[i] = tc
+			node.Children = append(node.Children, childNode)
+		}
+		return t.wrap(&traced, node), node, nil
+
+	case *orCondition:
+		node.Op = "Or"
+		traced := make(orCondition, len(*c))
+		for i, child := range *c {
+			tc, childNode, err := t.trace(db, child)
+			if err != nil {
+				return nil, nil, err
+			}
+			traced[i] = tc
+			node.Children = append(node.Children, childNode)
+		}
+		return t.wrap(&traced, node), node, nil
+
+	case *notCondition:
+		// Not isn't wrapped itself, so And still excludes its documents instead of inverting them
+		node.Op = "Not"
+		tc, childNode, err := t.trace(db, c.Condition)
+		if err != nil {
+			return nil, nil, err
+		}
+		node.Children = []*PlanNode{childNode}
+		return &notCondition{&seqCondition{tc, node, t}}, node, nil
+
+	case *eqCondition:
+		node.Op, node.Field, node.Values = "Eq", c.Name, [][]byte{c.Value}
+	case *inCondition:
+		node.Op, node.Field, node.Values = "In", c.Name, c.Values
+	case *prefixCondition:
+		node.Op, node.Field, node.Values = "HasPrefix", c.Name, [][]byte{c.Prefix}
+	case *rangeCondition:
+		node.Op, node.Field = rangeOp(c.From, c.To), c.Name
+		node.Values = [][]byte{nil, nil}
+		if c.From != nil {
+			node.Values[0] = c.From.val
+		}
+		if c.To != nil {
+			node.Values[1] = c.To.val
+		}
+	default:
+		node.Op = fmt.Sprintf("%T", c)
+	}
+
+	if node.Field != "" {
+		if err := describeIndex(db, node); err != nil {
+			return nil, nil, err
+		}
+	}
+	return t.wrap(c, node), node, nil
+}
+
+func (t *planTracer) wrap(c Condition, node *PlanNode) *tracedCondition {
+	return &tracedCondition{Condition: c, node: node, tracer: t}
+}
+
+func rangeOp(from, to *bound) string {
+	switch {
+	case from != nil && to != nil:
+		return "Between"
+	case from != nil && from.inclusive:
+		return "Gte"
+	case from != nil:
+		return "Gt"
+	case to != nil && to.inclusive:
+		return "Lte"
+	default:
+		return "Lt"
+	}
+}
+
+func describeIndex(db *DB, node *PlanNode) error {
+	f, err := db.filter(node.Field)
+	if err != nil {
+		return err
+	}
+	if f != nil {
+		node.Index, node.IndexValues = "filterable", f.vals.Size()
+		return nil
+	}
+	s, err := db.sorter(node.Field)
+	if err != nil {
+		return err
+	}
+	if s != nil {
+		node.Index, node.IndexValues = "sortable", s.vals.Size()
+	}
+	return nil
+}
+
+// tracedCondition evaluates the condition into a separate bitset to count the documents it matches.
+type tracedCondition struct {
+	Condition
+	node   *PlanNode
+	tracer *planTracer
+}
+
+func (c *tracedCondition) Set(ctx context.Context, db *DB, v BitSet) (bool, error) {
+	c.node.Seq = c.tracer.next()
+
+	res := acquireBitSet(v.Size())
+	defer releaseBitSet(res)
+
+	ok, err := c.Condition.Set(ctx, db, res)
+	if err != nil {
+		return false, err
+	}
+	c.node.Matched = res.Cardinality()
+	if !ok {
+		return false, nil
+	}
+	return v.Or(res)
+}
+
+// seqCondition only records the order of the evaluation of the condition.
+type seqCondition struct {
+	Condition
+	node   *PlanNode
+	tracer *planTracer
+}
+
+func (c *seqCondition) Set(ctx context.Context, db *DB, v BitSet) (bool, error) {
+	c.node.Seq = c.tracer.next()
+	return c.Condition.Set(ctx, db, v)
+}
+
+func (t *planTracer) next() int {
+	t.seq++
+	return t.seq
+}
@@ -0,0 +1,114 @@
+package yoctodb
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDB_Explain(t *testing.T) {
+	db := newTestDB(
+		testDoc{"brand": "audi", "color": "red"},
+		testDoc{"brand": "bmw", "color": "red"},
+		testDoc{"brand": "audi", "color": "blue"},
+		testDoc{"brand": "ford", "color": "red"},
+	)
+
+	plan, err := db.Explain(context.Background(), &Select{
+		Where: And(
+			Eq("color", []byte("red")),
+			Not(Eq("brand", []byte("bmw"))),
+			Or(Eq("brand", []byte("audi")), Gte("brand", []byte("f"))),
+		),
+		OrderBy: Desc("brand"),
+		Limit:   1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plan.Matched != 2 {
+		t.Errorf("Matched want 2, got %d", plan.Matched)
+	}
+	if want := "sort(brand desc) top=1"; plan.Scorer != want {
+		t.Errorf("Scorer want %q, got %q", want, plan.Scorer)
+	}
+
+	root := plan.Root
+	if root.Op != "And" || root.Seq != 1 || root.EarlyExit || len(root.Children) != 3 {
+		t.Fatalf("unexpected root node %+v", root)
+	}
+	eq, not, or := root.Children[0], root.Children[1], root.Children[2]
+	if eq.Seq != 2 || eq.Field != "color" || eq.Index != "filterable" || eq.IndexValues != 2 || eq.Matched != 3 {
+		t.Errorf("unexpected Eq node %+v", eq)
+	}
+	if not.Op != "Not" || not.Seq != 3 || not.Matched != 3 || not.Children[0].Seq != 4 || not.Children[0].Matched != 1 {
+		t.Errorf("unexpected Not node %+v", not)
+	}
+	if or.Op != "Or" || or.Seq != 5 || or.Matched != 3 {
+		t.Errorf("unexpected Or node %+v", or)
+	}
+	if gte := or.Children[1]; gte.Op != "Gte" || gte.Seq != 7 || gte.Matched != 1 || gte.Values[1] != nil {
+		t.Errorf("unexpected Gte node %+v", gte)
+	}
+
+	if s := plan.String(); !strings.Contains(s, `#4 Eq brand ["bmw"] index=filterable/3 matched=1`) {
+		t.Errorf("unexpected plan string:\n%s", s)
+	}
+}
+
+func TestDB_ExplainEarlyExit(t *testing.T) {
+	db := newTestDB(testDoc{"brand": "audi"}, testDoc{"brand": "bmw"})
+
+	plan, err := db.Explain(context.Background(), &Select{
+		Where: And(Eq("brand", []byte("ford")), Eq("brand", []byte("audi"))),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Matched != 0 || plan.Scorer != "id" {
+		t.Errorf("unexpected plan %+v", plan)
+	}
+	if !plan.Root.EarlyExit || plan.Root.Children[1].Seq != 0 {
+		t.Errorf("expect And to exit before evaluating its second child, got %+v", plan.Root)
+	}
+	if !strings.Contains(plan.String(), "early-exit") {
+		t.Errorf("unexpected plan string:\n%s", plan)
+	}
+}
+
+func TestDB_ExplainNestedEarlyExit(t *testing.T) {
+	db := newTestDB(
+		testDoc{"brand": "audi", "year": "2016"},
+		testDoc{"brand": "bmw", "year": "2017"},
+	)
+
+	plan, err := db.Explain(context.Background(), &Select{
+		Where: And(Eq("brand", []byte("kia")), And(Eq("brand", []byte("bmw")), Eq("year", []byte("2017")))),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Root.EarlyExit {
+		t.Errorf("expect outer And to exit early, got %+v", plan.Root)
+	}
+	inner := plan.Root.Children[1]
+	if inner.Seq != 0 || inner.EarlyExit {
+		t.Errorf("expect inner And to not be evaluated and not exit early, got %+v", inner)
+	}
+	if s := plan.String(); !strings.Contains(s, "#- And matched=0\n") {
+		t.Errorf("unexpected plan string:\n%s", s)
+	}
+}
+
+func TestDB_ExplainNoCondition(t *testing.T) {
+	db := newTestDB(testDoc{"brand": "audi"}, testDoc{"brand": "bmw"})
+
+	plan, err := db.Explain(context.Background(), &Select{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Root != nil || plan.Matched != 2 || plan.Scorer != "id" {
+		t.Errorf("unexpected plan %+v", plan)
+	}
+}
@@ -0,0 +1,98 @@
+package yoctodb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Facet is the number of documents with the value of a field.
+type Facet struct {
+	Value []byte
+	Count int
+}
+
+// facetCtxCheckInterval is the number of values counted between checks of the context.
+const facetCtxCheckInterval = 1024
+
+// Facets counts the documents matching the query per each value of the field.
+//
+// The query is filtered once, and its documents are intersected with the documents of every value
+// of the field. Facets are sorted by count in descending order, and then by value. Values without
+// matching documents are omitted. If topN is positive, only the first topN facets are returned.
+// Limit and offset of the query are ignored.
+func (db *DB) Facets(ctx context.Context, q Query, field string, topN int) ([]Facet, error) {
+	index, err := db.filterable(field)
+	if err != nil {
+		return nil, err
+	}
+	if index == nil {
+		return nil, fmt.Errorf("no filterable index for field %q", field)
+	}
+
+	bs, err := q.filteredUnlimited(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, nil
+	}
+	defer releaseBitSet(bs)
+
+	counts, err := index.facetCounts(ctx, bs)
+	if err != nil {
+		return nil, err
+	}
+
+	sortValCounts(counts)
+	if topN > 0 && len(counts) > topN {
+		counts = counts[:topN]
+	}
+
+	facets := make([]Facet, len(counts))
+	for i, c := range counts {
+		val, err := index.vals.Get(c.val)
+		if err != nil {
+			return nil, err
+		}
+		facets[i] = Facet{val, c.count}
+	}
+	return facets, nil
+}
+
+// valCount is the number of documents of the value index.
+type valCount struct {
+	val   int
+	count int
+}
+
+// sortValCounts sorts counts in descending order, and then by value.
+func sortValCounts(counts []valCount) {
+	// values are sorted, so value indexes order equal counts by value
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].count != counts[j].count {
+			return counts[i].count > counts[j].count
+		}
+		return counts[i].val < counts[j].val
+	})
+}
+
+// facetCounts counts documents of bs per each value of the index, omitting values without documents.
+func (f *FilterableIndex) facetCounts(ctx context.Context, bs BitSet) ([]valCount, error) {
+	var counts []valCount
+	for n := 0; n < f.vals.Size(); n++ {
+		if n%facetCtxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		count, err := f.valToDocs.andCount(n, bs)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			counts = append(counts, valCount{n, count})
+		}
+	}
+	return counts, nil
+}
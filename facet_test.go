@@ -0,0 +1,99 @@
+package yoctodb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDB_Facets(t *testing.T) {
+	db := newTestDB(
+		testDoc{"brand": "bmw", "color": "red"},
+		testDoc{"brand": "audi", "color": "red"},
+		testDoc{"brand": "ford", "color": "blue"},
+		testDoc{"brand": "audi", "color": "red"},
+		testDoc{"brand": "ford", "color": "red"},
+		testDoc{"brand": "vw", "color": "blue"},
+	)
+	ctx := context.Background()
+
+	tests := []struct {
+		Query Query
+		TopN  int
+		Want  []Facet
+	}{
+		{
+			&Select{},
+			0,
+			[]Facet{{[]byte("audi"), 2}, {[]byte("ford"), 2}, {[]byte("bmw"), 1}, {[]byte("vw"), 1}},
+		},
+		{
+			&Select{Where: Eq("color", []byte("red"))},
+			0,
+			[]Facet{{[]byte("audi"), 2}, {[]byte("bmw"), 1}, {[]byte("ford"), 1}},
+		},
+		{
+			&Select{Where: Eq("color", []byte("red")), Limit: 1},
+			2,
+			[]Facet{{[]byte("audi"), 2}, {[]byte("bmw"), 1}},
+		},
+		{
+			&Select{Where: Eq("color", []byte("green"))},
+			0,
+			nil,
+		},
+	}
+
+	for n, tc := range tests {
+		got, err := db.Facets(ctx, tc.Query, "brand", tc.TopN)
+		if err != nil {
+			t.Fatalf("case %d: %v", n, err)
+		}
+		if !reflect.DeepEqual(got, tc.Want) {
+			t.Errorf("case %d: want %v, got %v", n, tc.Want, got)
+		}
+	}
+
+	if _, err := db.Facets(ctx, &Select{}, "model", 0); err == nil {
+		t.Error("Facets() of unknown field expected to fail")
+	}
+}
+
+func TestDB_FacetsListMultiMap(t *testing.T) {
+	b := NewDBBuilder()
+	for n := 0; n < 300; n++ {
+		b.Add(NewDocumentBuilder().
+			WithField("id", []byte(fmt.Sprintf("id-%03d", n)), IndexFilterable).
+			WithField("parity", []byte(fmt.Sprint(n%2)), IndexFilterable))
+	}
+
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	db, err := ReadVerifyDB(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := db.Filter("id").valToDocs.(*listIndexToIndexMultiMap); !ok {
+		t.Fatalf("expect high-cardinality field to be list-based, got %T", db.Filter("id").valToDocs)
+	}
+
+	facets, err := db.Facets(context.Background(), &Select{Where: Eq("parity", []byte("1"))}, "id", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Facet{{[]byte("id-001"), 1}, {[]byte("id-003"), 1}}; !reflect.DeepEqual(facets, want) {
+		t.Fatalf("want facets %v, got %v", want, facets)
+	}
+
+	facets, err = db.Facets(context.Background(), &Select{}, "id", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Facet{{[]byte("id-000"), 1}}; !reflect.DeepEqual(facets, want) {
+		t.Fatalf("want facets %v, got %v", want, facets)
+	}
+}
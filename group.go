@@ -0,0 +1,170 @@
+package yoctodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Grouping describes how to group the documents matching a query.
+type Grouping struct {
+	// Fields are the filterable fields to group the documents by, one field per level of groups.
+	Fields []string
+	// TopK is the number of top documents to collect per group. Zero means none, and negative values
+	// are rejected.
+	TopK int
+	// OrderBy orders the top documents of a group. Documents are ordered by index, if it is nil.
+	OrderBy newScorerFunc
+}
+
+// Group is a bucket of the documents with the same value of a field.
+type Group struct {
+	Field string
+	Value []byte
+	// Count is the number of documents in the group.
+	Count int
+	// TopDocs are the indexes of the top documents in the group.
+	TopDocs []int
+	// Subgroups group the documents of the group by the next field.
+	Subgroups []*Group
+}
+
+// GroupBy groups the documents matching the query by the values of the fields.
+//
+// Groups of every level are sorted by count in descending order, and then by value. A document with
+// several values of a field is counted in the groups of every value. Limit and offset of the query
+// are ignored.
+func (db *DB) GroupBy(ctx context.Context, q Query, g *Grouping) ([]*Group, error) {
+	if g == nil || len(g.Fields) == 0 {
+		return nil, errors.New("no fields to group by")
+	}
+	if g.TopK < 0 {
+		return nil, fmt.Errorf("negative number of top documents %d", g.TopK)
+	}
+
+	indexes := make([]*FilterableIndex, len(g.Fields))
+	for i, field := range g.Fields {
+		index, err := db.filterable(field)
+		if err != nil {
+			return nil, err
+		}
+		if index == nil {
+			return nil, fmt.Errorf("no filterable index for field %q", field)
+		}
+		indexes[i] = index
+	}
+
+	bs, err := q.filteredUnlimited(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, nil
+	}
+	defer releaseBitSet(bs)
+
+	return db.group(ctx, g, indexes, bs)
+}
+
+// group groups documents of bs by the values of the first index, and the documents of each group
+// by the rest of indexes.
+func (db *DB) group(ctx context.Context, g *Grouping, indexes []*FilterableIndex, bs BitSet) ([]*Group, error) {
+	index := indexes[0]
+
+	counts, err := index.facetCounts(ctx, bs)
+	if err != nil {
+		return nil, err
+	}
+	sortValCounts(counts)
+
+	groups := make([]*Group, len(counts))
+	for i, c := range counts {
+		val, err := index.vals.Get(c.val)
+		if err != nil {
+			return nil, err
+		}
+		group := &Group{
+			Field: index.Name,
+			Value: val,
+			Count: c.count,
+		}
+		if g.TopK > 0 || len(indexes) > 1 {
+			if err := db.fillGroup(ctx, g, indexes, c.val, bs, group); err != nil {
+				return nil, err
+			}
+		}
+		groups[i] = group
+	}
+	return groups, nil
+}
+
+// fillGroup collects the top documents and the subgroups of the group of value index val.
+func (db *DB) fillGroup(ctx context.Context, g *Grouping, indexes []*FilterableIndex, val int, bs BitSet, group *Group) error {
+	groupBs := acquireBitSet(bs.Size())
+	defer releaseBitSet(groupBs)
+
+	if _, err := indexes[0].valToDocs.Get(val, groupBs); err != nil {
+		return err
+	}
+	if _, err := groupBs.And(bs); err != nil {
+		return err
+	}
+
+	if g.TopK > 0 {
+		docs, err := db.topDocs(ctx, g, groupBs)
+		if err != nil {
+			return err
+		}
+		group.TopDocs = docs
+	}
+
+	if len(indexes) > 1 {
+		subgroups, err := db.group(ctx, g, indexes[1:], groupBs)
+		if err != nil {
+			return err
+		}
+		group.Subgroups = subgroups
+	}
+	return nil
+}
+
+// topDocs returns the first TopK documents of bs in the order of the grouping.
+func (db *DB) topDocs(ctx context.Context, g *Grouping, bs BitSet) ([]int, error) {
+	// the scorer releases its BitSet, when it is closed
+	scorerBs := acquireBitSet(bs.Size())
+	if _, err := scorerBs.Or(bs); err != nil {
+		releaseBitSet(scorerBs)
+		return nil, err
+	}
+
+	var (
+		scorer Scorer
+		err    error
+	)
+	if g.OrderBy != nil {
+		scorer, err = g.OrderBy(ctx, db, scorerBs, g.TopK)
+		if err != nil {
+			releaseBitSet(scorerBs)
+			return nil, err
+		}
+	} else {
+		scorer = &idScorer{db: db, bs: scorerBs}
+	}
+
+	docs := make([]int, 0, g.TopK)
+	for len(docs) < g.TopK {
+		n, ok, err := scorer.next()
+		if err != nil {
+			scorer.close()
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		docs = append(docs, n)
+	}
+	if err := scorer.close(); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
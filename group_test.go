@@ -0,0 +1,82 @@
+package yoctodb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDB_GroupBy(t *testing.T) {
+	db := newTestDB(
+		testDoc{"brand": "audi", "model": "a4", "price": "300"},
+		testDoc{"brand": "bmw", "model": "x5", "price": "500"},
+		testDoc{"brand": "audi", "model": "a6", "price": "400"},
+		testDoc{"brand": "audi", "model": "a4", "price": "200"},
+		testDoc{"brand": "ford", "model": "f150", "price": "350"},
+		testDoc{"brand": "bmw", "model": "x5", "price": "450"},
+	)
+	ctx := context.Background()
+
+	groups, err := db.GroupBy(ctx, &Select{Where: Not(Eq("brand", []byte("ford")))}, &Grouping{
+		Fields:  []string{"brand", "model"},
+		TopK:    2,
+		OrderBy: Desc("price"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Group{
+		{
+			Field: "brand", Value: []byte("audi"), Count: 3, TopDocs: []int{2, 0},
+			Subgroups: []*Group{
+				{Field: "model", Value: []byte("a4"), Count: 2, TopDocs: []int{0, 3}},
+				{Field: "model", Value: []byte("a6"), Count: 1, TopDocs: []int{2}},
+			},
+		},
+		{
+			Field: "brand", Value: []byte("bmw"), Count: 2, TopDocs: []int{1, 5},
+			Subgroups: []*Group{
+				{Field: "model", Value: []byte("x5"), Count: 2, TopDocs: []int{1, 5}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("want %v, got %v", want, groups)
+	}
+
+	groups, err = db.GroupBy(ctx, &Select{}, &Grouping{Fields: []string{"brand"}, TopK: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []*Group{
+		{Field: "brand", Value: []byte("audi"), Count: 3, TopDocs: []int{0}},
+		{Field: "brand", Value: []byte("bmw"), Count: 2, TopDocs: []int{1}},
+		{Field: "brand", Value: []byte("ford"), Count: 1, TopDocs: []int{4}},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("want %v, got %v", want, groups)
+	}
+}
+
+func TestDB_GroupByErrors(t *testing.T) {
+	db := newTestDB(testDoc{"brand": "audi"})
+	ctx := context.Background()
+
+	if _, err := db.GroupBy(ctx, &Select{}, &Grouping{}); err == nil {
+		t.Error("GroupBy() without fields expected to fail")
+	}
+	if _, err := db.GroupBy(ctx, &Select{}, nil); err == nil {
+		t.Error("GroupBy() without grouping expected to fail")
+	}
+	if _, err := db.GroupBy(ctx, &Select{}, &Grouping{Fields: []string{"brand"}, TopK: -1}); err == nil {
+		t.Error("GroupBy() with negative TopK expected to fail")
+	}
+	if _, err := db.GroupBy(ctx, &Select{}, &Grouping{Fields: []string{"model"}}); err == nil {
+		t.Error("GroupBy() of unknown field expected to fail")
+	}
+	_, err := db.GroupBy(ctx, &Select{}, &Grouping{Fields: []string{"brand"}, TopK: 1, OrderBy: Asc("model")})
+	if err == nil {
+		t.Error("GroupBy() ordered by unknown field expected to fail")
+	}
+}
@@ -0,0 +1,40 @@
+//go:build go1.23
+
+package yoctodb
+
+import (
+	"iter"
+)
+
+// All returns an iterator over the remaining documents and their payloads.
+//
+// Documents are closed when the iteration stops, including the early exit from the loop.
+// The iteration stops on the first error, which is reported by Err after the loop. Documents of the DB
+// without payload are yielded with nil payload.
+func (d *Documents) All() iter.Seq2[int, []byte] {
+	return func(yield func(int, []byte) bool) {
+		defer d.closeErr()
+		for d.Next() {
+			rawData, err := d.document()
+			if err != nil {
+				return
+			}
+			if !yield(d.currentDoc, rawData) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over the values of the field in sorted order.
+// The iteration stops after the first value, which couldn't be read, is yielded with the error.
+func (f *FilterableIndex) Values() iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		for i := 0; i < f.vals.Size(); i++ {
+			val, err := f.vals.Get(i)
+			if !yield(val, err) || err != nil {
+				return
+			}
+		}
+	}
+}
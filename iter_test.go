@@ -0,0 +1,123 @@
+//go:build go1.23
+
+package yoctodb_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/narqo/yoctodb"
+)
+
+func TestDocuments_All(t *testing.T) {
+	db := buildTestDB(t, newTestCarsDBBuilder())
+
+	docs, err := db.Query(context.Background(), &yoctodb.Select{
+		Where:   yoctodb.Eq("color", []byte("FF0000")),
+		OrderBy: yoctodb.Asc("price"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		gotIDs      []int
+		gotPayloads []string
+	)
+	for d, rawData := range docs.All() {
+		gotIDs = append(gotIDs, d)
+		gotPayloads = append(gotPayloads, string(rawData))
+	}
+	if err := docs.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{2, 4, 0}; !reflect.DeepEqual(gotIDs, want) {
+		t.Errorf("want ids %v, got %v", want, gotIDs)
+	}
+	if want := []string{"audi:100", "ford:200", "audi:300"}; !reflect.DeepEqual(gotPayloads, want) {
+		t.Errorf("want payloads %v, got %v", want, gotPayloads)
+	}
+}
+
+func TestDocuments_All_Break(t *testing.T) {
+	db := buildTestDB(t, newTestCarsDBBuilder())
+
+	docs, err := db.Query(context.Background(), &yoctodb.Select{Where: yoctodb.Eq("brand", []byte("bmw"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d := range docs.All() {
+		if d != 1 {
+			t.Fatalf("want first document 1, got %d", d)
+		}
+		break
+	}
+	if docs.Next() {
+		t.Fatal("expect documents to be closed after break")
+	}
+	if err := docs.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDocuments_All_WithoutPayload(t *testing.T) {
+	db := buildTestDB(t, newTestCarsDBBuilder().WithoutPayload())
+
+	docs, err := db.Query(context.Background(), &yoctodb.Select{Where: yoctodb.Eq("brand", []byte("bmw"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for d, rawData := range docs.All() {
+		if rawData != nil {
+			t.Errorf("document %d: want nil payload, got %q", d, rawData)
+		}
+		ids = append(ids, d)
+	}
+	if err := docs.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 3}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("want documents %v, got %v", want, ids)
+	}
+}
+
+func TestDocuments_All_Error(t *testing.T) {
+	data := newTestCarsData(t)
+	readErr := errors.New("read failed")
+	r := &readerAtStats{r: bytes.NewReader(data), err: readErr, maxRead: 16}
+
+	db, err := yoctodb.LoadDB(r, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := db.Query(context.Background(), &yoctodb.Select{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d := range docs.All() {
+		t.Fatalf("unexpected document %d", d)
+	}
+	if err := docs.Err(); err != readErr {
+		t.Fatalf("Err() want %v, got %v", readErr, err)
+	}
+}
+
+func TestFilterableIndex_Values(t *testing.T) {
+	db := buildTestDB(t, newTestCarsDBBuilder())
+
+	var got []string
+	for val, err := range db.Filter("tag").Values() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(val))
+	}
+	if want := []string{"coupe", "pickup", "sedan", "sport"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
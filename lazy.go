@@ -0,0 +1,292 @@
+package yoctodb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// LoadDB loads the database of size bytes from r lazily. Only headers of the segments are read
+// up front, and every segment is read from r the first time it is used.
+//
+// Because segments are read on demand, errors of reading them are reported by the queries.
+// The checksum of the database is not verified.
+func LoadDB(r io.ReaderAt, size int64) (*DB, error) {
+	header := make([]byte, len(dbFormatMagic)+8)
+	if err := readFullAt(r, header[:len(dbFormatMagic)+4], 0); err != nil {
+		return nil, fmt.Errorf("could not read header: %v", err)
+	}
+	d := &bytesDecoder{header}
+
+	// check the magic
+	rawMagic, _ := d.next(len(dbFormatMagic))
+	if !bytes.Equal(dbFormatMagic, rawMagic) {
+		return nil, ErrWrongMagic
+	}
+
+	// check format version
+	version, _ := d.uint32()
+	if version != DBFormatVersion && version != DBFormatVersion6 {
+		return nil, fmt.Errorf("version format %d is not supported", version)
+	}
+
+	offset := int64(len(dbFormatMagic) + 4)
+
+	// check document count
+	docCount := -1
+	if version == DBFormatVersion6 {
+		rawCount := header[offset:]
+		if err := readFullAt(r, rawCount, offset); err != nil {
+			return nil, fmt.Errorf("could not read document count: %v", err)
+		}
+		docCount = int(binary.BigEndian.Uint32(rawCount))
+		offset += 4
+	}
+
+	end := size - dbFormatDigestSize
+	if end < offset {
+		return nil, ErrCorruptedData
+	}
+
+	db := &DB{
+		version: int(version),
+		filters: make(map[string]*FilterableIndex),
+		sorters: make(map[string]*SortableIndex),
+		lazy: &lazySegments{
+			filters: make(map[string]*lazySegment),
+			sorters: make(map[string]*lazySegment),
+		},
+	}
+
+	var rawHeader [segmentHeaderSize]byte
+	for offset < end {
+		if err := readFullAt(r, rawHeader[:], offset); err != nil {
+			return nil, fmt.Errorf("could not read segment header: %v", err)
+		}
+		segment := &lazySegment{
+			r:        r,
+			typ:      binary.BigEndian.Uint32(rawHeader[8:]),
+			offset:   offset + segmentHeaderSize,
+			size:     int64(binary.BigEndian.Uint64(rawHeader[0:])),
+			docCount: docCount,
+		}
+		if segment.size < 0 || segment.size > end-segment.offset {
+			return nil, errUnexpectedEnd
+		}
+		offset = segment.offset + segment.size
+
+		switch segment.typ {
+		case PayloadFull, PayloadNone:
+			if db.payload != nil {
+				return nil, errors.New("duplicate payload")
+			}
+			payload, err := newLazyPayload(segment)
+			if err != nil {
+				return nil, err
+			}
+			db.payload = payload
+
+		case FixedLenFilterableIndex, VarLenFilterableIndex:
+			name, err := segment.readName()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := db.lazy.filters[name]; ok {
+				return nil, fmt.Errorf("duplicate filterable index for field %q", name)
+			}
+			db.lazy.filters[name] = segment
+
+		case FixedLenSortableIndex, VarLenSortableIndex:
+			name, err := segment.readName()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := db.lazy.sorters[name]; ok {
+				return nil, fmt.Errorf("duplicate sortable index for field %q", name)
+			}
+			db.lazy.sorters[name] = segment
+
+		case FixedLenFullIndexSegment, VarLenFullIndexSegment:
+			name, err := segment.readName()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := db.lazy.filters[name]; ok {
+				return nil, fmt.Errorf("duplicate filterable index for field %q", name)
+			}
+			if _, ok := db.lazy.sorters[name]; ok {
+				return nil, fmt.Errorf("duplicate sortable index for field %q", name)
+			}
+			db.lazy.filters[name] = segment
+			db.lazy.sorters[name] = segment
+		}
+	}
+
+	if db.payload == nil {
+		return nil, ErrNoPayload
+	}
+
+	if docCount == -1 {
+		docCount = db.payload.documentsCount()
+	} else if err := checkSegmentDocumentsCount(db.payload, docCount); err != nil {
+		return nil, err
+	}
+	for _, segment := range db.lazy.filters {
+		segment.docCount = docCount
+	}
+	for _, segment := range db.lazy.sorters {
+		segment.docCount = docCount
+	}
+
+	return db, nil
+}
+
+// lazySegments holds segments of the DB, which are read on the first use.
+type lazySegments struct {
+	filters map[string]*lazySegment
+	sorters map[string]*lazySegment
+}
+
+func (l *lazySegments) filter(name string) (*FilterableIndex, error) {
+	segment, ok := l.filters[name]
+	if !ok {
+		return nil, nil
+	}
+	v, err := segment.load()
+	if err != nil {
+		return nil, err
+	}
+	if full, ok := v.(*FullIndex); ok {
+		return &full.FilterableIndex, nil
+	}
+	return v.(*FilterableIndex), nil
+}
+
+func (l *lazySegments) sorter(name string) (*SortableIndex, error) {
+	segment, ok := l.sorters[name]
+	if !ok {
+		return nil, nil
+	}
+	v, err := segment.load()
+	if err != nil {
+		return nil, err
+	}
+	if full, ok := v.(*FullIndex); ok {
+		return &full.SortableIndex, nil
+	}
+	return v.(*SortableIndex), nil
+}
+
+// lazySegment is a segment, which is read from r the first time it is loaded.
+type lazySegment struct {
+	r      io.ReaderAt
+	typ    uint32
+	offset int64
+	size   int64
+	// docCount is the number of documents in the DB to check the segment against
+	docCount int
+
+	once    sync.Once
+	segment interface{}
+	err     error
+}
+
+// readName reads the name of the index segment without reading the whole segment.
+func (s *lazySegment) readName() (string, error) {
+	var rawLen [4]byte
+	if s.size < int64(len(rawLen)) {
+		return "", errUnexpectedEnd
+	}
+	if err := readFullAt(s.r, rawLen[:], s.offset); err != nil {
+		return "", err
+	}
+	n := int64(binary.BigEndian.Uint32(rawLen[:]))
+	if n > s.size-int64(len(rawLen)) {
+		return "", errUnexpectedEnd
+	}
+	rawName := make([]byte, n)
+	if err := readFullAt(s.r, rawName, s.offset+int64(len(rawLen))); err != nil {
+		return "", err
+	}
+	return string(rawName), nil
+}
+
+func (s *lazySegment) load() (interface{}, error) {
+	s.once.Do(func() {
+		data := make([]byte, s.size)
+		if err := readFullAt(s.r, data, s.offset); err != nil {
+			s.err = err
+			return
+		}
+		segment, err := readSegment(s.typ, data)
+		if err != nil {
+			s.err = err
+			return
+		}
+		if err := checkSegmentDocumentsCount(segment, s.docCount); err != nil {
+			s.err = err
+			return
+		}
+		s.segment = segment
+	})
+	return s.segment, s.err
+}
+
+// lazyPayload is a payload segment, which is read on the first access to a document.
+// The number of documents is read up front.
+type lazyPayload struct {
+	segment *lazySegment
+	count   int
+}
+
+func newLazyPayload(segment *lazySegment) (*lazyPayload, error) {
+	// the number of documents goes first in PayloadNone segment, and after the chunk length in PayloadFull
+	offset := segment.offset
+	if segment.typ == PayloadFull {
+		offset += 8
+	}
+	var rawCount [4]byte
+	if offset+int64(len(rawCount)) > segment.offset+segment.size {
+		return nil, errUnexpectedEnd
+	}
+	if err := readFullAt(segment.r, rawCount[:], offset); err != nil {
+		return nil, fmt.Errorf("could not read document count: %v", err)
+	}
+
+	p := &lazyPayload{
+		segment: segment,
+		count:   int(binary.BigEndian.Uint32(rawCount[:])),
+	}
+	segment.docCount = p.count
+	return p, nil
+}
+
+func (p *lazyPayload) documentsCount() int {
+	return p.count
+}
+
+func (p *lazyPayload) document(i int) ([]byte, error) {
+	if i < 0 || i >= p.count {
+		return nil, errOutOfBounds
+	}
+	v, err := p.segment.load()
+	if err != nil {
+		return nil, err
+	}
+	return v.(payloadSegment).document(i)
+}
+
+// readFullAt reads exactly len(b) bytes from r starting at offset.
+func readFullAt(r io.ReaderAt, b []byte, offset int64) error {
+	n, err := r.ReadAt(b, offset)
+	if n == len(b) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
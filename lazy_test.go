@@ -0,0 +1,134 @@
+package yoctodb_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/narqo/yoctodb"
+)
+
+// readerAtStats counts bytes read from the underlying io.ReaderAt.
+type readerAtStats struct {
+	r io.ReaderAt
+	n int
+	// err is returned for reads larger than maxRead, if maxRead is set
+	err     error
+	maxRead int
+}
+
+func (r *readerAtStats) ReadAt(p []byte, off int64) (int, error) {
+	if r.maxRead > 0 && len(p) > r.maxRead {
+		return 0, r.err
+	}
+	n, err := r.r.ReadAt(p, off)
+	r.n += n
+	return n, err
+}
+
+func newTestCarsData(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if _, err := newTestCarsDBBuilder().WithVersion(yoctodb.DBFormatVersion6).WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLoadDB(t *testing.T) {
+	data := newTestCarsData(t)
+	r := &readerAtStats{r: bytes.NewReader(data)}
+
+	db, err := yoctodb.LoadDB(r, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := db.DocumentsCount(); n != len(testCars) {
+		t.Fatalf("DocumentsCount() want %d, got %d", len(testCars), n)
+	}
+	if db.Version() != yoctodb.DBFormatVersion6 {
+		t.Fatalf("Version() want %d, got %d", yoctodb.DBFormatVersion6, db.Version())
+	}
+
+	headersRead := r.n
+
+	ctx := context.Background()
+	n, err := db.Count(ctx, &yoctodb.Select{Where: yoctodb.Eq("brand", []byte("audi"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("Count() want 2, got %d", n)
+	}
+	if r.n <= headersRead {
+		t.Fatal("expect filterable index to be read on query")
+	}
+	if r.n >= len(data)/2 {
+		t.Fatalf("expect only the queried index to be read, got %d of %d bytes", r.n, len(data))
+	}
+
+	got := queryPayloads(t, db, &yoctodb.Select{
+		Where:   yoctodb.Eq("color", []byte("FF0000")),
+		OrderBy: yoctodb.Desc("price"),
+	})
+	if want := []string{"audi:300", "ford:200", "audi:100"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	if db.Filter("model") != nil {
+		t.Error("Filter() of unknown field want nil")
+	}
+}
+
+func TestLoadDB_IndexFull(t *testing.T) {
+	b := yoctodb.NewDBBuilder()
+	for _, car := range testCars {
+		b.Add(yoctodb.NewDocumentBuilder().
+			WithField("price", []byte(car.Price), yoctodb.IndexFull).
+			WithPayload([]byte(car.Brand + ":" + car.Price)))
+	}
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := yoctodb.LoadDB(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := queryPayloads(t, db, &yoctodb.Select{
+		Where:   yoctodb.Gte("price", []byte("200")),
+		OrderBy: yoctodb.Asc("price"),
+	})
+	if want := []string{"bmw:200", "ford:200", "audi:300", "bmw:300"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestLoadDB_ReadError(t *testing.T) {
+	data := newTestCarsData(t)
+	readErr := errors.New("read failed")
+	// headers are read in small pieces, while the whole segments don't fit
+	r := &readerAtStats{r: bytes.NewReader(data), err: readErr, maxRead: 16}
+
+	db, err := yoctodb.LoadDB(r, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Count(context.Background(), &yoctodb.Select{Where: yoctodb.Eq("brand", []byte("audi"))})
+	if err != readErr {
+		t.Fatalf("Count() want %v, got %v", readErr, err)
+	}
+	if _, err := db.Document(0); err != readErr {
+		t.Fatalf("Document() want %v, got %v", readErr, err)
+	}
+	if db.Sorter("price") != nil {
+		t.Fatal("Sorter() of unreadable index want nil")
+	}
+}
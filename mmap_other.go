@@ -0,0 +1,21 @@
+//go:build !unix
+
+package yoctodb
+
+import (
+	"io"
+	"os"
+)
+
+// mmapFile reads file f into memory, on platforms where memory-mapping is not supported.
+func mmapFile(f *os.File, size int64) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func munmapFile(data []byte) error {
+	return nil
+}
@@ -1,15 +1,19 @@
 package yoctodb
 
 import (
+	"context"
 	"errors"
+	"fmt"
 )
 
 type Query interface {
 	// filteredUnlimited calculates filtering result.
-	filteredUnlimited(db *DB) (BitSet, error)
-	exec(db *DB) (*Documents, error)
+	filteredUnlimited(ctx context.Context, db *DB) (BitSet, error)
+	exec(ctx context.Context, db *DB) (*Documents, error)
 	limit() (uint, error)
 	offset() (uint, error)
+	// explain executes the query and reports how it was executed.
+	explain(ctx context.Context, db *DB) (*Plan, error)
 }
 
 type Select struct {
@@ -21,13 +25,16 @@ type Select struct {
 
 var _ Query = &Select{}
 
-func (s *Select) filteredUnlimited(db *DB) (BitSet, error) {
+func (s *Select) filteredUnlimited(ctx context.Context, db *DB) (BitSet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if s.Where == nil {
 		bs := readOnlyOneBitSet(db.DocumentsCount())
 		return bs, nil
 	}
 	bs := acquireBitSet(db.DocumentsCount())
-	ok, err := s.Where.Set(db, bs)
+	ok, err := s.Where.Set(ctx, db, bs)
 	if err != nil {
 		releaseBitSet(bs)
 		return nil, err
@@ -39,8 +46,8 @@ func (s *Select) filteredUnlimited(db *DB) (BitSet, error) {
 	return bs, nil
 }
 
-func (s *Select) exec(db *DB) (*Documents, error) {
-	bs, err := s.filteredUnlimited(db)
+func (s *Select) exec(ctx context.Context, db *DB) (*Documents, error) {
+	bs, err := s.filteredUnlimited(ctx, db)
 	if err != nil {
 		return nil, err
 	}
@@ -52,10 +59,23 @@ func (s *Select) exec(db *DB) (*Documents, error) {
 	if err != nil {
 		return nil, err
 	}
+	limit, err := s.limit()
+	if err != nil {
+		return nil, err
+	}
 
 	var scorer Scorer
 	if s.OrderBy != nil {
-		scorer = s.OrderBy(db, bs)
+		// scorer only needs to order the documents up to the end of the requested page
+		var topK int
+		if limit > 0 {
+			topK = int(offset + limit)
+		}
+		scorer, err = s.OrderBy(ctx, db, bs, topK)
+		if err != nil {
+			releaseBitSet(bs)
+			return nil, err
+		}
 	} else {
 		scorer = &idScorer{
 			db: db,
@@ -64,9 +84,11 @@ func (s *Select) exec(db *DB) (*Documents, error) {
 	}
 
 	docs := &Documents{
+		ctx:        ctx,
 		db:         db,
 		scorer:     scorer,
 		skip:       int(offset),
+		limit:      int(limit),
 		currentDoc: -1,
 	}
 	return docs, nil
@@ -82,7 +104,7 @@ func (s *Select) offset() (uint, error) {
 
 type Condition interface {
 	// Set sets bits for the Documents satisfying condition and leave all the other bits untouched.
-	Set(db *DB, v BitSet) (bool, error)
+	Set(ctx context.Context, db *DB, v BitSet) (bool, error)
 }
 
 func Eq(name string, val []byte) Condition {
@@ -94,14 +116,89 @@ type eqCondition struct {
 	Value []byte
 }
 
-func (c *eqCondition) Set(db *DB, v BitSet) (bool, error) {
-	index := db.Filter(c.Name)
+func (c *eqCondition) Set(ctx context.Context, db *DB, v BitSet) (bool, error) {
+	index, err := db.filterable(c.Name)
 	if index == nil {
-		return false, nil
+		return false, err
 	}
 	return index.Eq(c.Value, v)
 }
 
+// In matches documents with values of the field equal to any of vals.
+func In(name string, vals ...[]byte) Condition {
+	return &inCondition{name, vals}
+}
+
+type inCondition struct {
+	Name   string
+	Values [][]byte
+}
+
+func (c *inCondition) Set(ctx context.Context, db *DB, v BitSet) (bool, error) {
+	index, err := db.filterable(c.Name)
+	if index == nil {
+		return false, err
+	}
+	return index.In(c.Values, v)
+}
+
+// HasPrefix matches documents with values of the field starting with prefix.
+func HasPrefix(name string, prefix []byte) Condition {
+	return &prefixCondition{name, prefix}
+}
+
+type prefixCondition struct {
+	Name   string
+	Prefix []byte
+}
+
+func (c *prefixCondition) Set(ctx context.Context, db *DB, v BitSet) (bool, error) {
+	index, err := db.filterable(c.Name)
+	if index == nil {
+		return false, err
+	}
+	return index.HasPrefix(c.Prefix, v)
+}
+
+// Gt matches documents with values of the field greater than val.
+func Gt(name string, val []byte) Condition {
+	return &rangeCondition{name, &bound{val, false}, nil}
+}
+
+// Gte matches documents with values of the field greater than or equal to val.
+func Gte(name string, val []byte) Condition {
+	return &rangeCondition{name, &bound{val, true}, nil}
+}
+
+// Lt matches documents with values of the field less than val.
+func Lt(name string, val []byte) Condition {
+	return &rangeCondition{name, nil, &bound{val, false}}
+}
+
+// Lte matches documents with values of the field less than or equal to val.
+func Lte(name string, val []byte) Condition {
+	return &rangeCondition{name, nil, &bound{val, true}}
+}
+
+// Between matches documents with values of the field between from and to.
+func Between(name string, from []byte, fromInclusive bool, to []byte, toInclusive bool) Condition {
+	return &rangeCondition{name, &bound{from, fromInclusive}, &bound{to, toInclusive}}
+}
+
+type rangeCondition struct {
+	Name string
+	From *bound
+	To   *bound
+}
+
+func (c *rangeCondition) Set(ctx context.Context, db *DB, v BitSet) (bool, error) {
+	index, err := db.filterable(c.Name)
+	if index == nil {
+		return false, err
+	}
+	return index.setRange(c.From, c.To, v)
+}
+
 func And(conditions ...Condition) Condition {
 	c := andCondition(conditions)
 	return &c
@@ -109,18 +206,18 @@ func And(conditions ...Condition) Condition {
 
 type andCondition []Condition
 
-func (c *andCondition) Set(db *DB, v BitSet) (bool, error) {
+func (c *andCondition) Set(ctx context.Context, db *DB, v BitSet) (bool, error) {
 	if len(*c) == 0 {
 		return false, errors.New("no conditions")
 	}
 	if len(*c) == 1 {
-		return c.setOne(0, db, v)
+		return c.setOne(ctx, 0, db, v)
 	}
 
 	res := acquireBitSet(v.Size())
 	defer releaseBitSet(res)
 
-	ok, err := c.setOne(0, db, res)
+	ok, err := c.setOne(ctx, 0, db, res)
 	if err != nil {
 		return false, err
 	}
@@ -132,16 +229,28 @@ func (c *andCondition) Set(db *DB, v BitSet) (bool, error) {
 	defer releaseBitSet(claRes)
 
 	for n := 1; n < len(*c); n++ {
-		claRes.Reset()
-
-		ok, err := c.setOne(n, db, claRes)
-		if err != nil {
+		if err := ctx.Err(); err != nil {
 			return false, err
 		}
-		if !ok {
-			return false, nil
+		claRes.Reset()
+
+		var anyBitSet bool
+		if not, ok := (*c)[n].(*notCondition); ok {
+			// exclude documents of the negated condition instead of inverting them
+			if _, err := not.Condition.Set(ctx, db, claRes); err != nil {
+				return false, err
+			}
+			anyBitSet, err = res.AndNot(claRes)
+		} else {
+			ok, err := c.setOne(ctx, n, db, claRes)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				return false, nil
+			}
+			anyBitSet, err = res.And(claRes)
 		}
-		anyBitSet, err := res.And(claRes)
 		if err != nil {
 			return false, err
 		}
@@ -153,8 +262,8 @@ func (c *andCondition) Set(db *DB, v BitSet) (bool, error) {
 	return v.Or(res)
 }
 
-func (c *andCondition) setOne(n int, db *DB, v BitSet) (bool, error) {
-	return (*c)[n].Set(db, v)
+func (c *andCondition) setOne(ctx context.Context, n int, db *DB, v BitSet) (bool, error) {
+	return (*c)[n].Set(ctx, db, v)
 }
 
 func Or(conditions ...Condition) Condition {
@@ -164,9 +273,12 @@ func Or(conditions ...Condition) Condition {
 
 type orCondition []Condition
 
-func (c *orCondition) Set(db *DB, v BitSet) (res bool, err error) {
+func (c *orCondition) Set(ctx context.Context, db *DB, v BitSet) (res bool, err error) {
 	for n := 0; n < len(*c); n++ {
-		anyBitSet, err := (*c)[n].Set(db, v)
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		anyBitSet, err := (*c)[n].Set(ctx, db, v)
 		if err != nil {
 			return false, err
 		}
@@ -177,24 +289,56 @@ func (c *orCondition) Set(db *DB, v BitSet) (res bool, err error) {
 	return
 }
 
+// Not matches documents, which don't match the condition.
+func Not(condition Condition) Condition {
+	return &notCondition{condition}
+}
+
+type notCondition struct {
+	Condition Condition
+}
+
+func (c *notCondition) Set(ctx context.Context, db *DB, v BitSet) (bool, error) {
+	res := acquireBitSet(v.Size())
+	defer releaseBitSet(res)
+
+	if _, err := c.Condition.Set(ctx, db, res); err != nil {
+		return false, err
+	}
+	ok, err := res.Inverse()
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+	return v.Or(res)
+}
+
 // Scorer is an iterator over documents matching query.
 type Scorer interface {
-	next(n int) (int, bool)
+	// next returns the next document in scorer's order. It returns false, when there are no more documents
+	// or an error occurred.
+	next() (int, bool, error)
 	close() error
 }
 
 type idScorer struct {
-	db *DB
-	bs BitSet
+	db  *DB
+	bs  BitSet
+	cur int
 }
 
-func (s *idScorer) next(n int) (int, bool) {
-	n = s.bs.NextSet(n)
-	ok := n >= 0
-	if !ok {
-		s.close()
+func (s *idScorer) next() (int, bool, error) {
+	if s.bs == nil {
+		return -1, false, nil
+	}
+	n := s.bs.NextSet(s.cur)
+	if n < 0 {
+		return -1, false, s.close()
 	}
-	return n, ok
+	s.cur = n + 1
+	return n, true, nil
 }
 
 func (s *idScorer) close() error {
@@ -205,71 +349,314 @@ func (s *idScorer) close() error {
 	return nil
 }
 
+// SortOrder is a direction of sorting.
+type SortOrder int
+
+const (
+	Ascending  SortOrder = 1
+	Descending SortOrder = -1
+)
+
+// Sort defines ordering of query results by the values of a sortable field.
+type Sort struct {
+	Name  string
+	Order SortOrder
+}
+
+type sortField struct {
+	Sort
+	index *SortableIndex
+}
+
+// sortingScorer iterates over documents ordered by values of one or more sortable fields.
+// Documents with equal values are ordered by the next field, and then by document index.
 type sortingScorer struct {
-	db      *DB
-	bs      BitSet
-	sorts   []string
-	indexes []*SortableIndex
+	db     *DB
+	bs     BitSet
+	fields []sortField
+	// limit is the number of top documents to keep. Zero means all documents.
+	limit int
+
+	docs []int
+	pos  int
 }
 
-func (s *sortingScorer) next(n int) (int, bool) {
-	panic("implement me")
+func (s *sortingScorer) next() (int, bool, error) {
+	if s.pos >= len(s.docs) {
+		return -1, false, s.close()
+	}
+	n := s.docs[s.pos]
+	s.pos++
+	return n, true, nil
 }
 
 func (s *sortingScorer) close() error {
-	panic("implement me")
+	if s.bs != nil {
+		releaseBitSet(s.bs)
+		s.bs = nil
+	}
+	s.docs = nil
+	return nil
+}
+
+// load collects matching documents and sorts them.
+//
+// If scorer's limit is set, only the top limit documents are kept, using a bounded heap.
+// The context is checked every sortCtxCheckInterval documents, both while collecting and sorting.
+func (s *sortingScorer) load(ctx context.Context) error {
+	size := s.bs.Cardinality()
+	bounded := s.limit > 0 && s.limit < size
+	if bounded {
+		size = s.limit + 1
+	}
+
+	docs := &sortedDocs{
+		fields: s.fields,
+		docs:   make([]int, 0, size),
+		vals:   make([]int, 0, size*len(s.fields)),
+	}
+	vals := make([]int, len(s.fields))
+	for i, n := 0, s.bs.NextSet(0); n >= 0; i, n = i+1, s.bs.NextSet(n+1) {
+		if i%sortCtxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		for k, f := range s.fields {
+			v, err := f.index.docToVals.Get(n)
+			if err != nil {
+				return fmt.Errorf("could not get value of field %q for document %d: %v", f.Name, n, err)
+			}
+			vals[k] = v
+		}
+		docs.push(n, vals)
+
+		if !bounded || docs.Len() <= s.limit {
+			if bounded && docs.Len() == s.limit {
+				docs.initHeap()
+			}
+			continue
+		}
+		// the heap is full: replace its top (the worst document) if the new one goes before it
+		last := docs.Len() - 1
+		if docs.Less(last, 0) {
+			docs.Swap(last, 0)
+			docs.pop()
+			docs.down(0, docs.Len())
+		} else {
+			docs.pop()
+		}
+	}
+	if err := docs.sort(ctx); err != nil {
+		return err
+	}
+
+	s.docs = docs.docs
+	return nil
+}
+
+// sortCtxCheckInterval is the number of documents sortingScorer loads or sorts between checks of the context.
+const sortCtxCheckInterval = 1024
+
+// sortedDocs holds documents and their value indexes, which are ordered by Less.
+type sortedDocs struct {
+	fields []sortField
+	docs   []int
+	// vals holds len(fields) value indexes per each document.
+	vals []int
+}
+
+func (d *sortedDocs) Len() int {
+	return len(d.docs)
+}
+
+func (d *sortedDocs) Less(i, j int) bool {
+	k := len(d.fields)
+	vi, vj := d.vals[i*k:i*k+k], d.vals[j*k:j*k+k]
+	for f := 0; f < k; f++ {
+		if vi[f] == vj[f] {
+			continue
+		}
+		if d.fields[f].Order == Descending {
+			return vi[f] > vj[f]
+		}
+		return vi[f] < vj[f]
+	}
+	return d.docs[i] < d.docs[j]
+}
+
+func (d *sortedDocs) push(n int, vals []int) {
+	d.docs = append(d.docs, n)
+	d.vals = append(d.vals, vals...)
+}
+
+func (d *sortedDocs) pop() {
+	d.docs = d.docs[:len(d.docs)-1]
+	d.vals = d.vals[:len(d.vals)-len(d.fields)]
+}
+
+// initHeap arranges documents into a heap with the greatest document on the top.
+func (d *sortedDocs) initHeap() {
+	n := d.Len()
+	for i := n/2 - 1; i >= 0; i-- {
+		d.down(i, n)
+	}
+}
+
+// sort sorts documents with heapsort, so that the context can be checked between the steps.
+// Less orders documents with equal values by document index, so the result is deterministic.
+func (d *sortedDocs) sort(ctx context.Context) error {
+	d.initHeap()
+	for n := d.Len() - 1; n > 0; n-- {
+		if n%sortCtxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		d.Swap(0, n)
+		d.down(0, n)
+	}
+	return nil
+}
+
+// down moves document i down the heap of the first n documents.
+func (d *sortedDocs) down(i, n int) {
+	for {
+		j := 2*i + 1
+		if j >= n {
+			break
+		}
+		if j+1 < n && d.Less(j, j+1) {
+			j++
+		}
+		if !d.Less(i, j) {
+			break
+		}
+		d.Swap(i, j)
+		i = j
+	}
+}
+
+func (d *sortedDocs) Swap(i, j int) {
+	d.docs[i], d.docs[j] = d.docs[j], d.docs[i]
+	k := len(d.fields)
+	vi, vj := d.vals[i*k:i*k+k], d.vals[j*k:j*k+k]
+	for f := 0; f < k; f++ {
+		vi[f], vj[f] = vj[f], vi[f]
+	}
 }
 
-type newScorerFunc func(db *DB, bs BitSet) Scorer
+// newScorerFunc creates a Scorer over documents of bs. Scorer may return only the first limit documents,
+// unless limit is zero.
+type newScorerFunc func(ctx context.Context, db *DB, bs BitSet, limit int) (Scorer, error)
 
-func newSortingScorer(db *DB, bs BitSet, order int, sorts ...string) Scorer {
+func newSortingScorer(ctx context.Context, db *DB, bs BitSet, limit int, sorts ...Sort) (Scorer, error) {
 	scorer := &sortingScorer{
-		db: db,
-		bs: bs,
-		sorts: sorts,
-		indexes: make([]*SortableIndex, len(sorts)),
+		db:     db,
+		bs:     bs,
+		fields: make([]sortField, len(sorts)),
+		limit:  limit,
 	}
 
-	for i, name := range sorts {
-		scorer.indexes[i] = db.Sorter(name)
+	for i, field := range sorts {
+		if field.Order != Ascending && field.Order != Descending {
+			return nil, fmt.Errorf("unknown sort order %d for field %q", field.Order, field.Name)
+		}
+		index, err := db.sorter(field.Name)
+		if err != nil {
+			return nil, err
+		}
+		if index == nil {
+			return nil, fmt.Errorf("no sortable index for field %q", field.Name)
+		}
+		scorer.fields[i] = sortField{field, index}
 	}
 
-	return scorer
+	if err := scorer.load(ctx); err != nil {
+		return nil, err
+	}
+	return scorer, nil
 }
 
-func Asc(sorts ...string) newScorerFunc {
-	return newScorerFunc(func(db *DB, bs BitSet) Scorer {
-		return newSortingScorer(db, bs, 1, sorts...)
+// OrderBy orders query results by sortable fields, each one in its own direction.
+func OrderBy(sorts ...Sort) newScorerFunc {
+	return newScorerFunc(func(ctx context.Context, db *DB, bs BitSet, limit int) (Scorer, error) {
+		return newSortingScorer(ctx, db, bs, limit, sorts...)
 	})
 }
 
-func Desc(sorts ...string) newScorerFunc {
-	return newScorerFunc(func(db *DB, bs BitSet) Scorer {
-		return newSortingScorer(db, bs, -1, sorts...)
-	})
+// Asc orders query results by sortable fields in ascending order.
+func Asc(names ...string) newScorerFunc {
+	return OrderBy(sortsOf(Ascending, names)...)
+}
+
+// Desc orders query results by sortable fields in descending order.
+func Desc(names ...string) newScorerFunc {
+	return OrderBy(sortsOf(Descending, names)...)
+}
+
+func sortsOf(order SortOrder, names []string) []Sort {
+	sorts := make([]Sort, len(names))
+	for i, name := range names {
+		sorts[i] = Sort{name, order}
+	}
+	return sorts
 }
 
 // Documents is an iterable collection of query execution results.
 type Documents struct {
+	ctx    context.Context
 	db     *DB
 	scorer Scorer
 
-	closed     bool
-	skip       int
+	closed bool
+	// skip is the number of documents left to skip before the first returned one.
+	skip int
+	// limit is the maximum number of documents to return. Zero means no limit.
+	limit      int
+	returned   int
 	currentDoc int
+
+	// err is the first error occurred while iterating the documents.
+	err error
 }
 
+// Next prepares the next document for reading with Scan. It returns false, when there are no more documents
+// or an error occurred, which is reported by Err. Documents are closed after.
 func (d *Documents) Next() (ok bool) {
 	if d.closed {
 		return false
 	}
-	d.currentDoc, ok = d.scorer.next(d.currentDoc + 1)
-	if !ok {
-		d.Close()
+	if d.err != nil || d.limit > 0 && d.returned >= d.limit {
+		d.closeErr()
+		return false
 	}
-	return ok
+	for {
+		if err := d.ctx.Err(); err != nil {
+			d.setErr(err)
+			d.closeErr()
+			return false
+		}
+		var err error
+		d.currentDoc, ok, err = d.scorer.next()
+		if err != nil {
+			d.setErr(err)
+		}
+		if !ok || err != nil {
+			d.closeErr()
+			return false
+		}
+		if d.skip == 0 {
+			break
+		}
+		d.skip--
+	}
+	d.returned++
+	return true
 }
 
+// Scan passes the current document and its payload to p. Documents of the DB without payload
+// are passed with nil payload.
 func (d *Documents) Scan(p DocumentProcessor) error {
 	if d.closed {
 		return errors.New("Documents are closes")
@@ -278,37 +665,64 @@ func (d *Documents) Scan(p DocumentProcessor) error {
 		return errors.New("Scan called without Next")
 	}
 
-	if d.skip > 0 {
-		d.skip -= 1
-		return nil
-	}
-
 	if p == nil {
 		return errors.New("no DocumentProcessor passed")
 	}
-	rawData, err := d.db.Document(d.currentDoc)
+	rawData, err := d.document()
 	if err != nil {
 		return err
 	}
 	return p.Process(d.currentDoc, rawData)
 }
 
+// document reads the payload of the current document and records the error of reading it.
+// The payload is nil, if the DB has no payload.
+func (d *Documents) document() ([]byte, error) {
+	rawData, err := d.db.Document(d.currentDoc)
+	if err == ErrNoPayload {
+		return nil, nil
+	}
+	if err != nil {
+		d.setErr(err)
+	}
+	return rawData, err
+}
+
+// Close releases resources used by the documents. It is safe to call Close multiple times.
 func (d *Documents) Close() error {
 	if d.closed {
 		return nil
 	}
-	if err := d.scorer.close(); err != nil {
-		return err
-	}
 	d.closed = true
-	return nil
+	return d.scorer.close()
 }
 
+// Err returns the error, if any, that was encountered during iteration.
 func (d *Documents) Err() error {
-	// TODO(varankinv): Documents.Err()
-	return nil
+	return d.err
+}
+
+func (d *Documents) setErr(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// closeErr closes the documents and records the error of closing.
+func (d *Documents) closeErr() {
+	if err := d.Close(); err != nil {
+		d.setErr(err)
+	}
 }
 
 type DocumentProcessor interface {
 	Process(d int, rawData []byte) error
 }
+
+// DocumentProcessorFunc is an adapter to allow the use of ordinary functions as DocumentProcessor.
+type DocumentProcessorFunc func(d int, rawData []byte) error
+
+// Process calls f(d, rawData).
+func (f DocumentProcessorFunc) Process(d int, rawData []byte) error {
+	return f(d, rawData)
+}
@@ -0,0 +1,545 @@
+package yoctodb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testDoc describes a document of the test database: field name to field value.
+type testDoc map[string]string
+
+// newTestDB builds an in-memory DB, where every field of docs is both filterable and sortable.
+func newTestDB(docs ...testDoc) *DB {
+	db := &DB{
+		filters: make(map[string]*FilterableIndex),
+		sorters: make(map[string]*SortableIndex),
+	}
+
+	fields := make(map[string]struct{})
+	payloads := make([][]byte, len(docs))
+	for n, doc := range docs {
+		for name := range doc {
+			fields[name] = struct{}{}
+		}
+		payloads[n] = []byte{byte(n)}
+	}
+	db.payload = &Payload{data: newTestVarLenSortedSet(payloads)}
+
+	for name := range fields {
+		var vals [][]byte
+		seen := make(map[string]bool)
+		for _, doc := range docs {
+			if v := doc[name]; !seen[v] {
+				seen[v] = true
+				vals = append(vals, []byte(v))
+			}
+		}
+		sort.Slice(vals, func(i, j int) bool {
+			return bytes.Compare(vals[i], vals[j]) < 0
+		})
+
+		wordSize := int(bitSetWordSize(uint(len(docs))))
+		valToDocs := &bitSetIndexToIndexMultiMap{
+			keysCount: len(vals),
+			size:      wordSize,
+			elems:     make([]byte, len(vals)*wordSize*8),
+		}
+		docToVals := &intIndexToIndexMap{
+			size:  len(docs),
+			elems: make([]byte, len(docs)*4),
+		}
+		for n, doc := range docs {
+			i := sort.Search(len(vals), func(i int) bool {
+				return bytes.Compare(vals[i], []byte(doc[name])) >= 0
+			})
+			binary.BigEndian.PutUint32(docToVals.elems[n*4:], uint32(i))
+			word := valToDocs.elems[(i*wordSize+n>>6)*8:]
+			binary.BigEndian.PutUint64(word, binary.BigEndian.Uint64(word)|1<<(uint(n)&63))
+		}
+
+		db.filters[name] = &FilterableIndex{
+			Name:      name,
+			vals:      newTestVarLenSortedSet(vals),
+			valToDocs: valToDocs,
+		}
+		db.sorters[name] = &SortableIndex{
+			FilterableIndex: *db.filters[name],
+			docToVals:       docToVals,
+		}
+	}
+
+	return db
+}
+
+func newTestVarLenSortedSet(vals [][]byte) *varLenSortedSet {
+	s := &varLenSortedSet{
+		size:    len(vals),
+		offsets: make([]byte, (len(vals)+1)*8),
+	}
+	for i, v := range vals {
+		s.elems = append(s.elems, v...)
+		binary.BigEndian.PutUint64(s.offsets[(i+1)*8:], uint64(len(s.elems)))
+	}
+	return s
+}
+
+type docIDsProcessor []int
+
+func (p *docIDsProcessor) Process(d int, rawData []byte) error {
+	*p = append(*p, d)
+	return nil
+}
+
+func queryDocIDs(t *testing.T, db *DB, q Query) []int {
+	t.Helper()
+
+	docs, err := db.Query(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer docs.Close()
+
+	var ids docIDsProcessor
+	for docs.Next() {
+		if err := docs.Scan(&ids); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ids
+}
+
+var testCars = []testDoc{
+	{"brand": "audi", "price": "300", "year": "2015"},
+	{"brand": "bmw", "price": "200", "year": "2017"},
+	{"brand": "audi", "price": "100", "year": "2017"},
+	{"brand": "bmw", "price": "300", "year": "2016"},
+	{"brand": "ford", "price": "200", "year": "2015"},
+}
+
+func TestSelect_OrderBy(t *testing.T) {
+	db := newTestDB(testCars...)
+
+	tests := []struct {
+		OrderBy newScorerFunc
+		Want    []int
+	}{
+		{nil, []int{0, 1, 2, 3, 4}},
+		{Asc("price"), []int{2, 1, 4, 0, 3}},
+		{Desc("price"), []int{0, 3, 1, 4, 2}},
+		{Asc("price", "year"), []int{2, 4, 1, 0, 3}},
+		{Desc("price", "year"), []int{3, 0, 1, 4, 2}},
+		{Asc("brand", "price"), []int{2, 0, 1, 3, 4}},
+		{Desc("year", "brand"), []int{1, 2, 3, 4, 0}},
+	}
+
+	for n, tc := range tests {
+		got := queryDocIDs(t, db, &Select{OrderBy: tc.OrderBy})
+		if !reflect.DeepEqual(got, tc.Want) {
+			t.Errorf("case %d: want %v, got %v", n, tc.Want, got)
+		}
+	}
+}
+
+func TestSelect_OrderByUnknownField(t *testing.T) {
+	db := newTestDB(testCars...)
+
+	_, err := db.Query(context.Background(), &Select{OrderBy: Asc("mileage")})
+	if err == nil {
+		t.Fatal("Query() ordered by unknown field expected to fail")
+	}
+}
+
+func TestSelect_OrderByMixed(t *testing.T) {
+	db := newTestDB(testCars...)
+
+	tests := []struct {
+		Sorts []Sort
+		Want  []int
+	}{
+		{[]Sort{{"price", Ascending}, {"year", Descending}}, []int{2, 1, 4, 3, 0}},
+		{[]Sort{{"price", Descending}, {"year", Ascending}}, []int{0, 3, 4, 1, 2}},
+		{[]Sort{{"brand", Descending}, {"price", Ascending}}, []int{4, 1, 3, 2, 0}},
+	}
+
+	for n, tc := range tests {
+		got := queryDocIDs(t, db, &Select{OrderBy: OrderBy(tc.Sorts...)})
+		if !reflect.DeepEqual(got, tc.Want) {
+			t.Errorf("case %d: want %v, got %v", n, tc.Want, got)
+		}
+	}
+
+	_, err := db.Query(context.Background(), &Select{OrderBy: OrderBy(Sort{"price", 0})})
+	if err == nil {
+		t.Fatal("Query() with unknown sort order expected to fail")
+	}
+}
+
+func TestSelect_LimitOffset(t *testing.T) {
+	db := newTestDB(testCars...)
+
+	tests := []struct {
+		OrderBy       newScorerFunc
+		Limit, Offset uint32
+		Want          []int
+	}{
+		{nil, 2, 0, []int{0, 1}},
+		{nil, 2, 2, []int{2, 3}},
+		{nil, 10, 3, []int{3, 4}},
+		{nil, 0, 4, []int{4}},
+		{nil, 1, 5, nil},
+		{Asc("price"), 2, 0, []int{2, 1}},
+		{Asc("price"), 2, 2, []int{4, 0}},
+		{Desc("price", "year"), 3, 1, []int{0, 1, 4}},
+		{Desc("price", "year"), 10, 0, []int{3, 0, 1, 4, 2}},
+	}
+
+	for n, tc := range tests {
+		q := &Select{OrderBy: tc.OrderBy, Limit: tc.Limit, Offset: tc.Offset}
+		got := queryDocIDs(t, db, q)
+		if !reflect.DeepEqual(got, tc.Want) {
+			t.Errorf("case %d: want %v, got %v", n, tc.Want, got)
+		}
+
+		count, err := db.Count(context.Background(), q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != len(tc.Want) {
+			t.Errorf("case %d: Count() want %d, got %d", n, len(tc.Want), count)
+		}
+	}
+}
+
+func TestSelect_LimitTopK(t *testing.T) {
+	docs := make([]testDoc, 200)
+	for n := range docs {
+		// values repeat to have ties broken by the second field and document index
+		docs[n] = testDoc{
+			"a": string(rune('a' + (n*7)%13)),
+			"b": string(rune('a' + (n*5)%3)),
+		}
+	}
+	db := newTestDB(docs...)
+
+	sorts := []Sort{{"a", Descending}, {"b", Ascending}}
+	all := queryDocIDs(t, db, &Select{OrderBy: OrderBy(sorts...)})
+	if len(all) != len(docs) {
+		t.Fatalf("want %d documents, got %d", len(docs), len(all))
+	}
+
+	for _, limit := range []uint32{1, 2, 10, 57, 199} {
+		for _, offset := range []uint32{0, 1, 30} {
+			q := &Select{OrderBy: OrderBy(sorts...), Limit: limit, Offset: offset}
+			got := queryDocIDs(t, db, q)
+
+			end := int(offset + limit)
+			if end > len(all) {
+				end = len(all)
+			}
+			if want := all[offset:end]; !reflect.DeepEqual(got, want) {
+				t.Errorf("limit %d, offset %d: want %v, got %v", limit, offset, want, got)
+			}
+		}
+	}
+}
+
+func TestHasPrefix(t *testing.T) {
+	db := newTestDB(
+		testDoc{"region": "eu/de/berlin"},
+		testDoc{"region": "eu/fr/paris"},
+		testDoc{"region": "eu/de/munich"},
+		testDoc{"region": "us/ny"},
+		testDoc{"region": "eu"},
+		testDoc{"region": "eu/de"},
+		testDoc{"region": "eu/dk"},
+		testDoc{"region": "eu/de\xff"},
+	)
+
+	tests := []struct {
+		Prefix string
+		Want   []int
+	}{
+		{"eu/de", []int{0, 2, 5, 7}},
+		{"eu/de/", []int{0, 2}},
+		{"eu/", []int{0, 1, 2, 5, 6, 7}},
+		{"eu", []int{0, 1, 2, 4, 5, 6, 7}},
+		{"us/ny", []int{3}},
+		{"us/ny/", nil},
+		{"ru", nil},
+		{"", []int{0, 1, 2, 3, 4, 5, 6, 7}},
+	}
+
+	for _, tc := range tests {
+		got := queryDocIDs(t, db, &Select{Where: HasPrefix("region", []byte(tc.Prefix))})
+		if !reflect.DeepEqual(got, tc.Want) {
+			t.Errorf("prefix %q: want %v, got %v", tc.Prefix, tc.Want, got)
+		}
+	}
+}
+
+func TestConditions(t *testing.T) {
+	db := newTestDB(testCars...)
+
+	tests := []struct {
+		Where Condition
+		Want  []int
+	}{
+		{Eq("brand", []byte("audi")), []int{0, 2}},
+		{Eq("brand", []byte("ford")), []int{4}},
+		{Eq("brand", []byte("kia")), nil},
+		{Gt("price", []byte("200")), []int{0, 3}},
+		{Gte("price", []byte("200")), []int{0, 1, 3, 4}},
+		{Lt("price", []byte("200")), []int{2}},
+		{Lte("price", []byte("200")), []int{1, 2, 4}},
+		{Gt("price", []byte("150")), []int{0, 1, 3, 4}},
+		{Lt("price", []byte("050")), nil},
+		{Gt("price", []byte("300")), nil},
+		{Between("year", []byte("2015"), true, []byte("2016"), true), []int{0, 3, 4}},
+		{Between("year", []byte("2015"), false, []byte("2017"), true), []int{1, 2, 3}},
+		{Between("year", []byte("2015"), false, []byte("2017"), false), []int{3}},
+		{Between("year", []byte("2017"), true, []byte("2015"), true), nil},
+		{Gte("mileage", []byte("0")), nil},
+		{And(Eq("brand", []byte("bmw")), Gte("year", []byte("2017"))), []int{1}},
+		{Or(Eq("brand", []byte("ford")), Lt("price", []byte("200"))), []int{2, 4}},
+		{In("brand", []byte("ford"), []byte("audi")), []int{0, 2, 4}},
+		{In("brand", []byte("kia"), []byte("bmw"), []byte("bmw"), []byte("acura")), []int{1, 3}},
+		{In("brand", []byte("kia"), []byte("zaz")), nil},
+		{In("brand"), nil},
+		{In("mileage", []byte("0")), nil},
+		{Not(Eq("brand", []byte("audi"))), []int{1, 3, 4}},
+		{Not(Eq("brand", []byte("kia"))), []int{0, 1, 2, 3, 4}},
+		{Not(Gte("price", []byte("100"))), nil},
+		{And(Gte("price", []byte("200")), Not(Eq("brand", []byte("bmw")))), []int{0, 4}},
+		{And(Not(Eq("brand", []byte("bmw"))), Eq("year", []byte("2017"))), []int{2}},
+		{And(Eq("brand", []byte("bmw")), Not(Eq("brand", []byte("bmw")))), nil},
+		{Or(Eq("brand", []byte("ford")), Not(Lte("year", []byte("2016")))), []int{1, 2, 4}},
+	}
+
+	for n, tc := range tests {
+		got := queryDocIDs(t, db, &Select{Where: tc.Where})
+		if !reflect.DeepEqual(got, tc.Want) {
+			t.Errorf("case %d: want %v, got %v", n, tc.Want, got)
+		}
+	}
+}
+
+// errScorer returns docs and then fails with err.
+type errScorer struct {
+	docs []int
+	err  error
+}
+
+func (s *errScorer) next() (int, bool, error) {
+	if len(s.docs) == 0 {
+		return -1, false, s.err
+	}
+	n := s.docs[0]
+	s.docs = s.docs[1:]
+	return n, true, nil
+}
+
+func (s *errScorer) close() error {
+	return nil
+}
+
+func TestDocuments_Err(t *testing.T) {
+	db := newTestDB(testDoc{"a": "1"}, testDoc{"a": "2"}, testDoc{"a": "3"})
+
+	scorerErr := errors.New("scorer failed")
+	docs := &Documents{
+		ctx:        context.Background(),
+		db:         db,
+		scorer:     &errScorer{docs: []int{2, 0}, err: scorerErr},
+		currentDoc: -1,
+	}
+	var got docIDsProcessor
+	for docs.Next() {
+		if err := docs.Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []int{2, 0}; !reflect.DeepEqual([]int(got), want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if err := docs.Err(); err != scorerErr {
+		t.Fatalf("Err() want %v, got %v", scorerErr, err)
+	}
+	if docs.Next() {
+		t.Fatal("Next() after error want false")
+	}
+}
+
+func TestDocuments_ErrPayload(t *testing.T) {
+	db := newTestDB(testDoc{"a": "1"}, testDoc{"a": "2"})
+	// corrupt the end offset of the second payload
+	payloads := db.payload.(*Payload).data.(*varLenSortedSet)
+	binary.BigEndian.PutUint64(payloads.offsets[16:], 100)
+
+	docs, err := db.Query(context.Background(), &Select{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer docs.Close()
+
+	var got docIDsProcessor
+	if !docs.Next() {
+		t.Fatal("Next() want true")
+	}
+	if err := docs.Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !docs.Next() {
+		t.Fatal("Next() want true")
+	}
+	if err := docs.Scan(&got); err == nil {
+		t.Fatal("Scan() of corrupted payload expected to fail")
+	}
+	if docs.Next() {
+		t.Fatal("Next() after error want false")
+	}
+	if docs.Err() == nil {
+		t.Fatal("Err() want payload error")
+	}
+}
+
+func TestDocuments_ErrCanceled(t *testing.T) {
+	db := newTestDB(testDoc{"a": "1"}, testDoc{"a": "2"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	docs, err := db.Query(ctx, &Select{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer docs.Close()
+
+	if !docs.Next() {
+		t.Fatal("Next() want true")
+	}
+	cancel()
+	if docs.Next() {
+		t.Fatal("Next() after cancel want false")
+	}
+	if err := docs.Err(); err != context.Canceled {
+		t.Fatalf("Err() want %v, got %v", context.Canceled, err)
+	}
+}
+
+// cancelCondition cancels the query context, when it is evaluated.
+type cancelCondition struct {
+	cancel context.CancelFunc
+}
+
+func (c *cancelCondition) Set(ctx context.Context, db *DB, v BitSet) (bool, error) {
+	c.cancel()
+	return true, nil
+}
+
+func TestQuery_Canceled(t *testing.T) {
+	db := newTestDB(testDoc{"a": "1"}, testDoc{"a": "2"}, testDoc{"a": "3"})
+
+	tests := []func(cancel context.CancelFunc) Condition{
+		func(cancel context.CancelFunc) Condition {
+			return Or(&cancelCondition{cancel}, Eq("a", []byte("1")))
+		},
+		func(cancel context.CancelFunc) Condition {
+			return And(&cancelCondition{cancel}, Eq("a", []byte("1")))
+		},
+		func(cancel context.CancelFunc) Condition {
+			return Not(Or(Eq("a", []byte("1")), &cancelCondition{cancel}, Eq("a", []byte("2"))))
+		},
+	}
+
+	for n, newCond := range tests {
+		ctx, cancel := context.WithCancel(context.Background())
+		q := &Select{Where: newCond(cancel)}
+		if _, err := db.Count(ctx, q); err != context.Canceled {
+			t.Errorf("case %d: Count() want %v, got %v", n, context.Canceled, err)
+		}
+
+		ctx, cancel = context.WithCancel(context.Background())
+		q = &Select{Where: newCond(cancel), OrderBy: Asc("a")}
+		if _, err := db.Query(ctx, q); err != context.Canceled {
+			t.Errorf("case %d: Query() want %v, got %v", n, context.Canceled, err)
+		}
+	}
+}
+
+func TestSortingScorer_Canceled(t *testing.T) {
+	db := newTestDB(testDoc{"a": "1"}, testDoc{"a": "2"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bs := readOnlyOneBitSet(db.DocumentsCount())
+	if _, err := newSortingScorer(ctx, db, bs, 0, Sort{"a", Ascending}); err != context.Canceled {
+		t.Fatalf("newSortingScorer() want %v, got %v", context.Canceled, err)
+	}
+}
+
+// countdownContext is canceled after its Err is called n times.
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (c *countdownContext) Err() error {
+	if c.n == 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestSortingScorer_CanceledSort(t *testing.T) {
+	const size = 3000
+	docs := make([]testDoc, size)
+	for n := range docs {
+		docs[n] = testDoc{"a": fmt.Sprintf("%02d", (size-n)%50)}
+	}
+	db := newTestDB(docs...)
+	bs := readOnlyOneBitSet(db.DocumentsCount())
+
+	// loading checks the context once per sortCtxCheckInterval documents, the next check is made by sorting
+	loadChecks := (size + sortCtxCheckInterval - 1) / sortCtxCheckInterval
+	ctx := &countdownContext{context.Background(), loadChecks}
+	if _, err := newSortingScorer(ctx, db, bs, 0, Sort{"a", Ascending}); err != context.Canceled {
+		t.Fatalf("newSortingScorer() want %v, got %v", context.Canceled, err)
+	}
+	if ctx.n != 0 {
+		t.Fatalf("expect the context to be checked %d times while loading", loadChecks)
+	}
+
+	scorer, err := newSortingScorer(context.Background(), db, bs, 0, Sort{"a", Ascending})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := -1
+	for i := 0; ; i++ {
+		n, ok, err := scorer.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			if i != size {
+				t.Fatalf("want %d documents, got %d", size, i)
+			}
+			break
+		}
+		if prev >= 0 && !lessTestDoc(docs[prev], prev, docs[n], n) {
+			t.Fatalf("document %d expected to go before %d", prev, n)
+		}
+		prev = n
+	}
+}
+
+func lessTestDoc(a testDoc, i int, b testDoc, j int) bool {
+	if a["a"] != b["a"] {
+		return a["a"] < b["a"]
+	}
+	return i < j
+}
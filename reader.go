@@ -8,12 +8,19 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/bits"
+	"os"
+	"sort"
 )
 
 var dbFormatMagic = []byte{0x40, 0xC7, 0x0D, 0xB1}
 
 const (
-	DBFormatVersion    = 5
+	DBFormatVersion = 5
+
+	// DBFormatVersion6 adds document count after the format version
+	DBFormatVersion6 = 6
+
 	dbFormatDigestSize = md5.Size
 )
 
@@ -59,10 +66,61 @@ func ReadVerifyDB(data io.Reader) (*DB, error) {
 	return readDB(data, true)
 }
 
+// OpenDB opens the database file at path. The file is memory-mapped and the segments of the database
+// refer to the mapped memory directly. The DB must be closed when no longer needed.
+func OpenDB(path string) (*DB, error) {
+	return openDB(path, false)
+}
+
+// OpenVerifyDB does the same as OpenDB, also verifying database's checksum.
+func OpenVerifyDB(path string) (*DB, error) {
+	return openDB(path, true)
+}
+
+func openDB(path string, verifyChecksum bool) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := mmapFile(f, fi.Size())
+	if err != nil {
+		return nil, fmt.Errorf("could not map file %s: %v", path, err)
+	}
+
+	db, err := parseDB(data, verifyChecksum)
+	if err != nil {
+		munmapFile(data)
+		return nil, err
+	}
+	db.closer = func() error {
+		return munmapFile(data)
+	}
+	return db, nil
+}
+
 func readDB(data io.Reader, verifyChecksum bool) (*DB, error) {
+	buf, err := ioutil.ReadAll(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not read data: %v", err)
+	}
+	return parseDB(buf, verifyChecksum)
+}
+
+// parseDB parses the database from buf. Segments of the database refer to buf directly, so buf must not
+// be modified after.
+func parseDB(buf []byte, verifyChecksum bool) (*DB, error) {
+	d := &bytesDecoder{buf}
+
 	// check the magic
-	rawMagic := make([]byte, len(dbFormatMagic))
-	if _, err := data.Read(rawMagic); err != nil {
+	rawMagic, err := d.next(len(dbFormatMagic))
+	if err != nil {
 		return nil, fmt.Errorf("could not read magic: %v", err)
 	}
 	if !bytes.Equal(dbFormatMagic, rawMagic) {
@@ -70,30 +128,26 @@ func readDB(data io.Reader, verifyChecksum bool) (*DB, error) {
 	}
 
 	// check format version
-	var version uint32
-	if err := readUint32(data, &version); err != nil {
+	version, err := d.uint32()
+	if err != nil {
 		return nil, fmt.Errorf("could not read version: %v", err)
 	}
 
-	if version != DBFormatVersion {
+	if version != DBFormatVersion && version != DBFormatVersion6 {
 		return nil, fmt.Errorf("version format %d is not supported", version)
 	}
-	//fmt.Printf("parsed version: %d\n", version)
 
 	// check document count
-	// TODO(varankinv): format version 6 adds document count
-	/*
-	if _, err := data.Read(header[:]); err != nil {
-		return fmt.Errorf("wrong document count: %v", err)
+	docCount := -1
+	if version == DBFormatVersion6 {
+		n, err := d.uint32()
+		if err != nil {
+			return nil, fmt.Errorf("could not read document count: %v", err)
+		}
+		docCount = int(n)
 	}
-	docCount := binary.BigEndian.Uint32(header)
-	fmt.Printf("parsed document count: %d\n", docCount)
-	*/
 
-	buf, err := ioutil.ReadAll(data)
-	if err != nil {
-		return nil, fmt.Errorf("could not read remaining data: %v", err)
-	}
+	buf = d.b
 	if len(buf) < dbFormatDigestSize {
 		return nil, ErrCorruptedData
 	}
@@ -109,11 +163,12 @@ func readDB(data io.Reader, verifyChecksum bool) (*DB, error) {
 	}
 
 	db := &DB{
+		version: int(version),
 		filters: make(map[string]*FilterableIndex),
 		sorters: make(map[string]*SortableIndex),
 	}
 
-	sr := NewSegmentReader(bytes.NewReader(body))
+	sr := newSegmentReader(body)
 	for !sr.Empty() {
 		segment, err := sr.ReadSegment()
 		if err != nil {
@@ -126,6 +181,12 @@ func readDB(data io.Reader, verifyChecksum bool) (*DB, error) {
 			}
 			db.payload = s
 
+		case *EmptyPayload:
+			if db.payload != nil {
+				return nil, errors.New("duplicate payload")
+			}
+			db.payload = s
+
 		case *FilterableIndex:
 			if _, ok := db.filters[s.Name]; ok {
 				return nil, fmt.Errorf("duplicate filterable index for field %q", s.Name)
@@ -137,6 +198,16 @@ func readDB(data io.Reader, verifyChecksum bool) (*DB, error) {
 				return nil, fmt.Errorf("duplicate sortable index for field %q", s.Name)
 			}
 			db.sorters[s.Name] = s
+
+		case *FullIndex:
+			if _, ok := db.filters[s.Name]; ok {
+				return nil, fmt.Errorf("duplicate filterable index for field %q", s.Name)
+			}
+			if _, ok := db.sorters[s.Name]; ok {
+				return nil, fmt.Errorf("duplicate sortable index for field %q", s.Name)
+			}
+			db.filters[s.Name] = &s.FilterableIndex
+			db.sorters[s.Name] = &s.SortableIndex
 		}
 	}
 
@@ -144,114 +215,185 @@ func readDB(data io.Reader, verifyChecksum bool) (*DB, error) {
 		return nil, ErrNoPayload
 	}
 
+	if docCount == -1 {
+		docCount = db.payload.documentsCount()
+	}
+	if err := checkDocumentsCount(db, docCount); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
 
+// checkDocumentsCount cross-checks sizes of DB segments with the number of documents.
+func checkDocumentsCount(db *DB, docCount int) error {
+	if err := checkSegmentDocumentsCount(db.payload, docCount); err != nil {
+		return err
+	}
+	for _, f := range db.filters {
+		if err := checkSegmentDocumentsCount(f, docCount); err != nil {
+			return err
+		}
+	}
+	for _, s := range db.sorters {
+		if err := checkSegmentDocumentsCount(s, docCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkSegmentDocumentsCount(segment interface{}, docCount int) error {
+	switch s := segment.(type) {
+	case payloadSegment:
+		if n := s.documentsCount(); n != docCount {
+			return fmt.Errorf("%w: payload has %d documents, want %d", ErrCorruptedData, n, docCount)
+		}
+	case *FilterableIndex:
+		if err := checkMultiMapSize(s.valToDocs, docCount); err != nil {
+			return fmt.Errorf("filterable index for field %q: %w", s.Name, err)
+		}
+	case *SortableIndex:
+		if err := checkMultiMapSize(s.valToDocs, docCount); err != nil {
+			return fmt.Errorf("sortable index for field %q: %w", s.Name, err)
+		}
+		if m, ok := s.docToVals.(*intIndexToIndexMap); ok && m.size != docCount {
+			return fmt.Errorf("sortable index for field %q: %w: maps %d documents, want %d",
+				s.Name, ErrCorruptedData, m.size, docCount)
+		}
+	case *FullIndex:
+		return checkSegmentDocumentsCount(&s.SortableIndex, docCount)
+	}
+	return nil
+}
+
+func checkMultiMapSize(m IndexToIndexMultiMap, docCount int) error {
+	if m, ok := m.(*bitSetIndexToIndexMultiMap); ok {
+		if wordSize := int(bitSetWordSize(uint(docCount))); m.size != wordSize {
+			return fmt.Errorf("%w: BitSets of %d words, want %d", ErrCorruptedData, m.size, wordSize)
+		}
+	}
+	return nil
+}
+
 const (
 	multimapListBased   uint32 = 1000 * (1 + iota)
 	multimapBitSetBased
 )
 
+// segmentHeaderSize is the size of segment's header (size + type)
+const segmentHeaderSize = 12
+
 type SegmentReader struct {
-	r *bytes.Reader
-	// header contains segment's header (size + type)
-	header [12]byte
+	data []byte
 	// offset contains segment's absolute offset
-	offset int64
+	offset int
 }
 
+// NewSegmentReader creates SegmentReader over the unread data of r.
 func NewSegmentReader(r *bytes.Reader) *SegmentReader {
+	// reading from bytes.Reader never fails
+	data, _ := ioutil.ReadAll(r)
+	return newSegmentReader(data)
+}
+
+func newSegmentReader(data []byte) *SegmentReader {
 	return &SegmentReader{
-		r: r,
+		data: data,
 	}
 }
 
 func (s *SegmentReader) Empty() bool {
-	return s.r.Len() == 0
+	return s.offset >= len(s.data)
 }
 
 func (s *SegmentReader) ReadSegment() (v interface{}, err error) {
-	if _, err = s.r.Read(s.header[:]); err != nil {
-		return
+	d := &bytesDecoder{s.data[s.offset:]}
+	size, err := d.uint64()
+	if err != nil {
+		return nil, err
+	}
+	typ, err := d.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if size > uint64(len(d.b)) {
+		return nil, errUnexpectedEnd
 	}
-	size := binary.BigEndian.Uint64(s.header[0:])
-	typ := binary.BigEndian.Uint32(s.header[8:])
 
 	//fmt.Printf("read segment: type %d, size %d\n", typ, size)
 
-	s.offset += int64(len(s.header)) + int64(size)
-
-	var segment interface{}
+	// skip to next segment
+	s.offset += segmentHeaderSize + int(size)
 
-	sr := io.LimitReader(s.r, int64(size))
+	return readSegment(typ, d.b[:size])
+}
 
+// readSegment decodes a segment of type typ. Segments of unknown types are skipped.
+func readSegment(typ uint32, data []byte) (segment interface{}, err error) {
 	switch typ {
 	case PayloadFull:
-		segment, err = s.readPayload(sr)
+		segment, err = readPayload(data)
 		if err != nil {
 			return nil, err
 		}
 
 	case PayloadNone:
-		var size uint32
-		if err := readUint32(sr, &size); err != nil {
+		size, err := (&bytesDecoder{data}).uint32()
+		if err != nil {
 			return nil, err
 		}
 		segment = &EmptyPayload{int(size)}
 
 	case FixedLenFilterableIndex, VarLenFilterableIndex:
-		segment, err = s.readFilterable(sr, typ)
+		segment, err = readFilterable(data, typ)
 		if err != nil {
 			return nil, err
 		}
 
 	case FixedLenSortableIndex, VarLenSortableIndex:
-		segment, err = s.readSortable(sr, typ)
+		segment, err = readSortable(data, typ)
 		if err != nil {
 			return nil, err
 		}
-	}
 
-	fmt.Printf("read segment %d: %#v\n", typ, segment)
-
-	// skip to next segment
-	if _, err := s.r.Seek(s.offset, io.SeekStart); err != nil {
-		return nil, err
+	case FixedLenFullIndexSegment, VarLenFullIndexSegment:
+		segment, err = readFull(data, typ)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return segment, nil
 }
 
-func (s *SegmentReader) readCommonSegmentFields(r io.Reader, typ uint32) (string, SortedSet, IndexToIndexMultiMap, error) {
-	var rawName []byte
-	if err := readBytes(r, &rawName); err != nil {
+func readCommonSegmentFields(d *bytesDecoder, typ uint32) (string, SortedSet, IndexToIndexMultiMap, error) {
+	rawName, err := d.bytes()
+	if err != nil {
 		return "", nil, nil, err
 	}
 	segmentName := string(rawName)
 
-	var chunkLen uint64
-	if err := readUint64(r, &chunkLen); err != nil {
+	chunk, err := d.chunk()
+	if err != nil {
 		return segmentName, nil, nil, err
 	}
-	if chunkLen == 0 {
+	if len(chunk) == 0 {
 		return segmentName, nil, nil, errors.New("empty segment")
 	}
 
 	var (
 		vals      SortedSet
 		valToDocs IndexToIndexMultiMap
-		err       error
 	)
 
-	if typ == FixedLenFilterableIndex || typ == FixedLenSortableIndex {
-		r := io.LimitReader(r, int64(chunkLen))
-		vals, err = NewFixedLenSortedSet(r)
+	if typ == FixedLenFilterableIndex || typ == FixedLenSortableIndex || typ == FixedLenFullIndexSegment {
+		vals, err = newFixedLenSortedSet(chunk)
 		if err != nil {
 			return segmentName, nil, nil, fmt.Errorf("could not read segment values set %d: %v", typ, err)
 		}
-	} else if typ == VarLenFilterableIndex || typ == VarLenSortableIndex {
-		r := io.LimitReader(r, int64(chunkLen))
-		vals, err = NewVarLenSortedSet(r)
+	} else if typ == VarLenFilterableIndex || typ == VarLenSortableIndex || typ == VarLenFullIndexSegment {
+		vals, err = newVarLenSortedSet(chunk)
 		if err != nil {
 			return segmentName, nil, nil, fmt.Errorf("could not read segment values set %d: %v", typ, err)
 		}
@@ -259,24 +401,29 @@ func (s *SegmentReader) readCommonSegmentFields(r io.Reader, typ uint32) (string
 		return segmentName, nil, nil, fmt.Errorf("unknown filterable segment type: %d", typ)
 	}
 
-	if err := readUint64(r, &chunkLen); err != nil {
+	chunk, err = d.chunk()
+	if err != nil {
 		return segmentName, nil, nil, err
 	}
-	if chunkLen == 0 {
+	if len(chunk) == 0 {
 		return segmentName, nil, nil, errors.New("empty segment")
 	}
 
-	idxr := io.LimitReader(r, int64(chunkLen))
+	idxd := &bytesDecoder{chunk}
 
-	var mmtyp uint32
-	if err := readUint32(idxr, &mmtyp); err != nil {
+	mmtyp, err := idxd.uint32()
+	if err != nil {
 		return segmentName, nil, nil, err
 	}
 
 	switch mmtyp {
 	case multimapListBased:
+		valToDocs, err = newListIndexToIndexMultiMap(idxd.b)
+		if err != nil {
+			return segmentName, nil, nil, fmt.Errorf("could not read segment valToDocs %d: %v", typ, err)
+		}
 	case multimapBitSetBased:
-		valToDocs, err = NewBitSetIndexToIndexMultiMap(idxr)
+		valToDocs, err = newBitSetIndexToIndexMultiMap(idxd.b)
 		if err != nil {
 			return segmentName, nil, nil, fmt.Errorf("could not read segment valToDocs %d: %v", typ, err)
 		}
@@ -287,8 +434,8 @@ func (s *SegmentReader) readCommonSegmentFields(r io.Reader, typ uint32) (string
 	return segmentName, vals, valToDocs, nil
 }
 
-func (s *SegmentReader) readFilterable(r io.Reader, typ uint32) (*FilterableIndex, error) {
-	segmentName, vals, valToDocs, err := s.readCommonSegmentFields(r, typ)
+func readFilterable(data []byte, typ uint32) (*FilterableIndex, error) {
+	segmentName, vals, valToDocs, err := readCommonSegmentFields(&bytesDecoder{data}, typ)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read segment %q: %v", segmentName, err)
 	}
@@ -297,43 +444,57 @@ func (s *SegmentReader) readFilterable(r io.Reader, typ uint32) (*FilterableInde
 		Name:      segmentName,
 		vals:      vals,
 		valToDocs: valToDocs,
+		typ:       typ,
+		size:      len(data),
 	}
 	return segment, nil
 }
 
-func (s *SegmentReader) readSortable(r io.Reader, typ uint32) (*SortableIndex, error) {
-	segmentName, vals, valToDocs, err := s.readCommonSegmentFields(r, typ)
+func readSortable(data []byte, typ uint32) (*SortableIndex, error) {
+	d := &bytesDecoder{data}
+	segmentName, vals, valToDocs, err := readCommonSegmentFields(d, typ)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read segment %q: %v", segmentName, err)
 	}
 
-	docToVals, err := NewIntIndexToIndexMap(r)
+	docToVals, err := newIntIndexToIndexMap(d.b)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read segment %q: could not read segment docToVals %d: %v",
 			segmentName, typ, err)
 	}
 
 	segment := &SortableIndex{
-		Name:      segmentName,
-		vals:      vals,
-		valToDocs: valToDocs,
+		FilterableIndex: FilterableIndex{
+			Name:      segmentName,
+			vals:      vals,
+			valToDocs: valToDocs,
+			typ:       typ,
+			size:      len(data),
+		},
 		docToVals: docToVals,
 	}
 	return segment, nil
 }
 
-func (s *SegmentReader) readPayload(r io.Reader) (v *Payload, err error) {
-	var chunkLen uint64
+// readFull reads a full segment, which has the same layout as a sortable one.
+func readFull(data []byte, typ uint32) (*FullIndex, error) {
+	s, err := readSortable(data, typ)
+	if err != nil {
+		return nil, err
+	}
+	return &FullIndex{*s}, nil
+}
 
-	if err := readUint64(r, &chunkLen); err != nil {
+func readPayload(data []byte) (v *Payload, err error) {
+	chunk, err := (&bytesDecoder{data}).chunk()
+	if err != nil {
 		return nil, err
 	}
-	if chunkLen == 0 {
+	if len(chunk) == 0 {
 		return nil, errors.New("empty segment")
 	}
 
-	r = io.LimitReader(r, int64(chunkLen))
-	payload, err := NewVarLenSortedSet(r)
+	payload, err := newVarLenSortedSet(chunk)
 	if err != nil {
 		return nil, fmt.Errorf("could not read segment payload %v", err)
 	}
@@ -355,6 +516,10 @@ type SortedSet interface {
 // IndexToIndexMultiMap stores an inverse mapping from a value index to document indexes.
 type IndexToIndexMultiMap interface {
 	Get(n int, v BitSet) (bool, error)
+	// andCount returns the number of documents of value index n, which are also set in v.
+	andCount(n int, v BitSet) (int, error)
+	// count returns the number of documents of value index n.
+	count(n int) (int, error)
 }
 
 // IndexToIndexMap stores a direct mapping from a document index to the value index.
@@ -369,6 +534,10 @@ type FilterableIndex struct {
 	Name      string
 	vals      SortedSet
 	valToDocs IndexToIndexMultiMap
+
+	// typ and size are the type and the size in bytes of the segment the index was read from
+	typ  uint32
+	size int
 }
 
 func (f *FilterableIndex) Eq(val []byte, v BitSet) (bool, error) {
@@ -378,22 +547,200 @@ func (f *FilterableIndex) Eq(val []byte, v BitSet) (bool, error) {
 	return false, nil
 }
 
+// In sets bits for documents with any of values vals.
+func (f *FilterableIndex) In(vals [][]byte, v BitSet) (res bool, err error) {
+	probes := make([][]byte, len(vals))
+	copy(probes, vals)
+	sort.Slice(probes, func(i, j int) bool {
+		return bytes.Compare(probes[i], probes[j]) < 0
+	})
+
+	// both probes and values are sorted, so every next probe is searched after the previous one
+	var n int
+	for _, val := range probes {
+		n, err = sortedSetSearchFrom(f.vals, n, val, false)
+		if err != nil {
+			return false, err
+		}
+		if n == f.vals.Size() {
+			break
+		}
+		cmp, err := f.vals.Compare(n, val)
+		if err != nil {
+			return false, err
+		}
+		if cmp != 0 {
+			continue
+		}
+		ok, err := f.valToDocs.Get(n, v)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			res = true
+		}
+		n++
+	}
+	return res, nil
+}
+
+// HasPrefix sets bits for documents with values starting with prefix.
+func (f *FilterableIndex) HasPrefix(prefix []byte, v BitSet) (bool, error) {
+	// all values with the prefix are not less than the prefix and less than its successor
+	start, err := sortedSetSearch(f.vals, prefix, false)
+	if err != nil {
+		return false, err
+	}
+	end := f.vals.Size()
+	if next := prefixSuccessor(prefix); next != nil {
+		end, err = sortedSetSearchFrom(f.vals, start, next, false)
+		if err != nil {
+			return false, err
+		}
+	}
+	return f.setValues(start, end, v)
+}
+
+// prefixSuccessor returns the smallest value greater than all values with the prefix.
+// It returns nil if there is no such value, e.g. the prefix is empty or consists of 0xFF bytes only.
+func prefixSuccessor(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xFF {
+			next := make([]byte, i+1)
+			copy(next, prefix)
+			next[i]++
+			return next
+		}
+	}
+	return nil
+}
+
+// Gt sets bits for documents with values greater than val.
+func (f *FilterableIndex) Gt(val []byte, v BitSet) (bool, error) {
+	return f.setRange(&bound{val, false}, nil, v)
+}
+
+// Gte sets bits for documents with values greater than or equal to val.
+func (f *FilterableIndex) Gte(val []byte, v BitSet) (bool, error) {
+	return f.setRange(&bound{val, true}, nil, v)
+}
+
+// Lt sets bits for documents with values less than val.
+func (f *FilterableIndex) Lt(val []byte, v BitSet) (bool, error) {
+	return f.setRange(nil, &bound{val, false}, v)
+}
+
+// Lte sets bits for documents with values less than or equal to val.
+func (f *FilterableIndex) Lte(val []byte, v BitSet) (bool, error) {
+	return f.setRange(nil, &bound{val, true}, v)
+}
+
+// Between sets bits for documents with values between from and to.
+func (f *FilterableIndex) Between(from []byte, fromInclusive bool, to []byte, toInclusive bool, v BitSet) (bool, error) {
+	return f.setRange(&bound{from, fromInclusive}, &bound{to, toInclusive}, v)
+}
+
+// bound is a boundary of a values range.
+type bound struct {
+	val       []byte
+	inclusive bool
+}
+
+// setRange sets bits for documents with values between from and to. Nil boundary means the range
+// is not bounded from that side.
+func (f *FilterableIndex) setRange(from, to *bound, v BitSet) (bool, error) {
+	// values are sorted, so the range is a contiguous span of value indexes
+	start, end := 0, f.vals.Size()
+	var err error
+	if from != nil {
+		start, err = sortedSetSearch(f.vals, from.val, !from.inclusive)
+		if err != nil {
+			return false, err
+		}
+	}
+	if to != nil {
+		end, err = sortedSetSearch(f.vals, to.val, to.inclusive)
+		if err != nil {
+			return false, err
+		}
+	}
+	return f.setValues(start, end, v)
+}
+
+// setValues sets bits for documents with value indexes in range [start, end).
+func (f *FilterableIndex) setValues(start, end int, v BitSet) (res bool, err error) {
+	for n := start; n < end; n++ {
+		ok, err := f.valToDocs.Get(n, v)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			res = true
+		}
+	}
+	return res, nil
+}
+
+// ValuesCount returns the number of distinct values of the field.
+func (f *FilterableIndex) ValuesCount() int {
+	return f.vals.Size()
+}
+
+// Value returns the value with index i. Values are indexed in sorted order.
+func (f *FilterableIndex) Value(i int) ([]byte, error) {
+	return f.vals.Get(i)
+}
+
+// ValueIndex returns the index of the value val or -1, if the field has no such value.
+func (f *FilterableIndex) ValueIndex(val []byte) int {
+	return f.vals.Index(val)
+}
+
+// DocumentFrequency returns the number of documents with the value with index i.
+func (f *FilterableIndex) DocumentFrequency(i int) (int, error) {
+	return f.valToDocs.count(i)
+}
+
 // SortableIndex is a sortable segment for each named sortable field.
 //
 // SortableIndex contains all fields that FilterableIndex do and a persistent
 // collection directly mapping a document index to the value index.
 type SortableIndex struct {
-	Name      string
-	vals      SortedSet
-	valToDocs IndexToIndexMultiMap
+	FilterableIndex
 	docToVals IndexToIndexMap
 }
 
+// payloadSegment is a segment, which stores documents' payload.
+type payloadSegment interface {
+	documentsCount() int
+	document(i int) ([]byte, error)
+}
+
+var (
+	_ payloadSegment = &Payload{}
+	_ payloadSegment = &EmptyPayload{}
+)
+
+// FullIndex is a full segment for each named field, which is both filterable and sortable.
+//
+// DB resolves both Filter and Sorter of the field to the FullIndex.
+type FullIndex struct {
+	SortableIndex
+}
+
 // Payload is an import payload segment.
 type Payload struct {
 	data SortedSet
 }
 
+func (p *Payload) documentsCount() int {
+	return p.Size()
+}
+
+func (p *Payload) document(i int) ([]byte, error) {
+	return p.Get(i)
+}
+
 func (p *Payload) Get(i int) ([]byte, error) {
 	return p.data.Get(i)
 }
@@ -407,6 +754,18 @@ type EmptyPayload struct {
 	Size int
 }
 
+func (p *EmptyPayload) documentsCount() int {
+	return p.Size
+}
+
+// document returns ErrNoPayload for any existing document.
+func (p *EmptyPayload) document(i int) ([]byte, error) {
+	if i < 0 || i >= p.Size {
+		return nil, errOutOfBounds
+	}
+	return nil, ErrNoPayload
+}
+
 var errOutOfBounds = errors.New("out of bounds")
 
 type fixedLenSortedSet struct {
@@ -416,17 +775,26 @@ type fixedLenSortedSet struct {
 }
 
 func NewFixedLenSortedSet(r io.Reader) (*fixedLenSortedSet, error) {
-	var err error
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return newFixedLenSortedSet(data)
+}
+
+func newFixedLenSortedSet(data []byte) (*fixedLenSortedSet, error) {
+	d := &bytesDecoder{data}
 
-	var size, elemSize uint32
-	if err := readUint32(r, &size); err != nil {
+	size, err := d.uint32()
+	if err != nil {
 		return nil, err
 	}
-	if err := readUint32(r, &elemSize); err != nil {
+	elemSize, err := d.uint32()
+	if err != nil {
 		return nil, err
 	}
 
-	data, err := ioutil.ReadAll(r)
+	elems, err := d.next(int(size) * int(elemSize))
 	if err != nil {
 		return nil, err
 	}
@@ -434,7 +802,7 @@ func NewFixedLenSortedSet(r io.Reader) (*fixedLenSortedSet, error) {
 	res := &fixedLenSortedSet{
 		size:     int(size),
 		elemSize: int(elemSize),
-		elems:    data,
+		elems:    elems,
 	}
 
 	return res, nil
@@ -447,7 +815,7 @@ func (v *fixedLenSortedSet) Get(i int) ([]byte, error) {
 	start := i * v.elemSize
 
 	buf := make([]byte, v.elemSize)
-	copy(buf, v.elems[start:v.elemSize])
+	copy(buf, v.elems[start:start+v.elemSize])
 
 	return buf, nil
 }
@@ -457,7 +825,7 @@ func (v *fixedLenSortedSet) Compare(i int, val []byte) (int, error) {
 		return 0, errOutOfBounds
 	}
 	start := i * v.elemSize
-	return bytes.Compare(v.elems[start:v.elemSize], val), nil
+	return bytes.Compare(v.elems[start:start+v.elemSize], val), nil
 }
 
 func (v *fixedLenSortedSet) Size() int {
@@ -475,23 +843,34 @@ type varLenSortedSet struct {
 }
 
 func NewVarLenSortedSet(r io.Reader) (*varLenSortedSet, error) {
-	var err error
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return newVarLenSortedSet(data)
+}
+
+func newVarLenSortedSet(data []byte) (*varLenSortedSet, error) {
+	d := &bytesDecoder{data}
 
-	var size uint32
-	if err := readUint32(r, &size); err != nil {
+	size, err := d.uint32()
+	if err != nil {
 		return nil, err
 	}
-	offsetsLen := (size + 1) << 3 // e.g. size of int64 elements in "offset" chunk
+	offsetsLen := (int(size) + 1) << 3 // e.g. size of int64 elements in "offset" chunk
 
-	data, err := ioutil.ReadAll(r)
+	offsets, err := d.next(offsetsLen)
 	if err != nil {
 		return nil, err
 	}
+	if end := binary.BigEndian.Uint64(offsets[offsetsLen-8:]); end > uint64(len(d.b)) {
+		return nil, errUnexpectedEnd
+	}
 
 	res := &varLenSortedSet{
 		size:    int(size),
-		offsets: data[:offsetsLen],
-		elems:   data[offsetsLen:],
+		offsets: offsets,
+		elems:   d.b,
 	}
 
 	return res, nil
@@ -505,7 +884,7 @@ func (v *varLenSortedSet) Get(i int) ([]byte, error) {
 	offsets := v.offsets[base:]
 	start := binary.BigEndian.Uint64(offsets)
 	end := binary.BigEndian.Uint64(offsets[8:])
-	if start > end {
+	if start > end || end > uint64(len(v.elems)) {
 		return nil, errOutOfBounds
 	}
 
@@ -524,7 +903,7 @@ func (v *varLenSortedSet) Compare(i int, val []byte) (int, error) {
 	offsets := v.offsets[base:]
 	start := binary.BigEndian.Uint64(offsets)
 	end := binary.BigEndian.Uint64(offsets[8:])
-	if start > end {
+	if start > end || end > uint64(len(v.elems)) {
 		return 0, errOutOfBounds
 	}
 	return bytes.Compare(v.elems[start:end], val), nil
@@ -553,12 +932,37 @@ func sortedSetIndexByte(v SortedSet, val []byte) int {
 		} else if idx < 0 {
 			start = mid + 1
 		} else {
-			return idx
+			return mid
 		}
 	}
 	return -1
 }
 
+// sortedSetSearch does a binary search of the first value in SortedSet v, which is not less than val.
+// If after is true, it searches the first value greater than val.
+// It returns v.Size() if there is no such value.
+func sortedSetSearch(v SortedSet, val []byte, after bool) (int, error) {
+	return sortedSetSearchFrom(v, 0, val, after)
+}
+
+// sortedSetSearchFrom does the same as sortedSetSearch, but only looks at values starting from index start.
+func sortedSetSearchFrom(v SortedSet, start int, val []byte, after bool) (int, error) {
+	end := v.Size()
+	for start < end {
+		mid := (start + end) >> 1
+		idx, err := v.Compare(mid, val)
+		if err != nil {
+			return 0, err
+		}
+		if idx < 0 || (after && idx == 0) {
+			start = mid + 1
+		} else {
+			end = mid
+		}
+	}
+	return start, nil
+}
+
 type bitSetIndexToIndexMultiMap struct {
 	keysCount int
 	size      int
@@ -568,24 +972,36 @@ type bitSetIndexToIndexMultiMap struct {
 var _ IndexToIndexMultiMap = &bitSetIndexToIndexMultiMap{}
 
 func NewBitSetIndexToIndexMultiMap(r io.Reader) (*bitSetIndexToIndexMultiMap, error) {
-	var n uint32
-	if err := readUint32(r, &n); err != nil {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
 		return nil, err
 	}
-	res := &bitSetIndexToIndexMultiMap{
-		keysCount: int(n),
-	}
+	return newBitSetIndexToIndexMultiMap(data)
+}
+
+func newBitSetIndexToIndexMultiMap(data []byte) (*bitSetIndexToIndexMultiMap, error) {
+	d := &bytesDecoder{data}
 
-	if err := readUint32(r, &n); err != nil {
+	keysCount, err := d.uint32()
+	if err != nil {
+		return nil, err
+	}
+	size, err := d.uint32()
+	if err != nil {
 		return nil, err
 	}
-	res.size = int(n)
 
-	var err error
-	if res.elems, err = ioutil.ReadAll(r); err != nil {
+	elems, err := d.next(int(keysCount) * int(size) << 3)
+	if err != nil {
 		return nil, err
 	}
 
+	res := &bitSetIndexToIndexMultiMap{
+		keysCount: int(keysCount),
+		size:      int(size),
+		elems:     elems,
+	}
+
 	return res, nil
 }
 
@@ -623,6 +1039,146 @@ func (m *bitSetIndexToIndexMultiMap) Get(n int, v BitSet) (bool, error) {
 	return notEmpty, nil
 }
 
+func (m *bitSetIndexToIndexMultiMap) andCount(n int, v BitSet) (int, error) {
+	if n < 0 || n >= m.keysCount {
+		return 0, errOutOfBounds
+	}
+	if wordSize := bitSetWordSize(uint(v.Size())); wordSize != uint(m.size) {
+		return 0, errors.New("size not equal")
+	}
+
+	var words []uint64
+	switch v := v.(type) {
+	case *bitSet:
+		words = v.words
+	case readOnlyOneBitSet:
+		return m.count(n)
+	case readOnlyZeroBitSet:
+		return 0, nil
+	default:
+		panic("implement me")
+	}
+
+	elems := m.elems[n*(m.size<<3):]
+	var count int
+	for i := 0; i < m.size; i++ {
+		count += bits.OnesCount64(binary.BigEndian.Uint64(elems[i<<3:]) & words[i])
+	}
+	return count, nil
+}
+
+func (m *bitSetIndexToIndexMultiMap) count(n int) (int, error) {
+	if n < 0 || n >= m.keysCount {
+		return 0, errOutOfBounds
+	}
+	elems := m.elems[n*(m.size<<3):]
+	var count int
+	for i := 0; i < m.size; i++ {
+		count += bits.OnesCount64(binary.BigEndian.Uint64(elems[i<<3:]))
+	}
+	return count, nil
+}
+
+// listIndexToIndexMultiMap stores a sorted list of document indexes for each value index.
+type listIndexToIndexMultiMap struct {
+	keysCount int
+	// offsets contains offsets of the lists in elems, one per each value index
+	offsets []byte
+	elems   []byte
+}
+
+var _ IndexToIndexMultiMap = &listIndexToIndexMultiMap{}
+
+func NewListIndexToIndexMultiMap(r io.Reader) (*listIndexToIndexMultiMap, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return newListIndexToIndexMultiMap(data)
+}
+
+func newListIndexToIndexMultiMap(data []byte) (*listIndexToIndexMultiMap, error) {
+	d := &bytesDecoder{data}
+
+	keysCount, err := d.uint32()
+	if err != nil {
+		return nil, err
+	}
+
+	offsets, err := d.next(int(keysCount) << 3)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &listIndexToIndexMultiMap{
+		keysCount: int(keysCount),
+		offsets:   offsets,
+		elems:     d.b,
+	}
+
+	return res, nil
+}
+
+// list returns the encoded list of document indexes for value index n.
+func (m *listIndexToIndexMultiMap) list(n int) ([]byte, error) {
+	if n < 0 || n >= m.keysCount {
+		return nil, errOutOfBounds
+	}
+	offset := binary.BigEndian.Uint64(m.offsets[n<<3:])
+	if offset > uint64(len(m.elems)) {
+		return nil, errUnexpectedEnd
+	}
+
+	// each list is prefixed with the number of elements
+	d := &bytesDecoder{m.elems[offset:]}
+	size, err := d.uint32()
+	if err != nil {
+		return nil, err
+	}
+	return d.next(int(size) << 2)
+}
+
+func (m *listIndexToIndexMultiMap) Get(n int, v BitSet) (bool, error) {
+	docs, err := m.list(n)
+	if err != nil {
+		return false, err
+	}
+	for len(docs) > 0 {
+		v.Set(int(binary.BigEndian.Uint32(docs)))
+		docs = docs[4:]
+	}
+	return v.NextSet(0) != -1, nil
+}
+
+func (m *listIndexToIndexMultiMap) count(n int) (int, error) {
+	docs, err := m.list(n)
+	if err != nil {
+		return 0, err
+	}
+	return len(docs) >> 2, nil
+}
+
+func (m *listIndexToIndexMultiMap) andCount(n int, v BitSet) (int, error) {
+	switch v.(type) {
+	case readOnlyOneBitSet:
+		return m.count(n)
+	case readOnlyZeroBitSet:
+		return 0, nil
+	}
+
+	docs, err := m.list(n)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for ; len(docs) > 0; docs = docs[4:] {
+		if d := int(binary.BigEndian.Uint32(docs)); d < v.Size() && v.Test(d) {
+			count++
+		}
+	}
+	return count, nil
+}
+
 type intIndexToIndexMap struct {
 	size  int
 	elems []byte
@@ -631,18 +1187,31 @@ type intIndexToIndexMap struct {
 var _ IndexToIndexMap = &intIndexToIndexMap{}
 
 func NewIntIndexToIndexMap(r io.Reader) (*intIndexToIndexMap, error) {
-	var n uint32
-	if err := readUint32(r, &n); err != nil {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
 		return nil, err
 	}
-	res := &intIndexToIndexMap{
-		size: int(n),
+	return newIntIndexToIndexMap(data)
+}
+
+func newIntIndexToIndexMap(data []byte) (*intIndexToIndexMap, error) {
+	d := &bytesDecoder{data}
+
+	size, err := d.uint32()
+	if err != nil {
+		return nil, err
 	}
-	var err error
-	if res.elems, err = ioutil.ReadAll(r); err != nil {
+
+	elems, err := d.next(int(size) << 2)
+	if err != nil {
 		return nil, err
 	}
 
+	res := &intIndexToIndexMap{
+		size:  int(size),
+		elems: elems,
+	}
+
 	return res, nil
 }
 
@@ -654,32 +1223,56 @@ func (m *intIndexToIndexMap) Get(n int) (int, error) {
 	return int(binary.BigEndian.Uint32(m.elems[k:])), nil
 }
 
-func readUint64(r io.Reader, v *uint64) error {
-	b := make([]byte, 8)
-	if _, err := r.Read(b); err != nil {
-		return err
+var errUnexpectedEnd = fmt.Errorf("%w: unexpected end of data", ErrCorruptedData)
+
+// bytesDecoder decodes values from a byte slice. Decoded byte slices refer to the original slice.
+type bytesDecoder struct {
+	b []byte
+}
+
+// next returns the next n bytes.
+func (d *bytesDecoder) next(n int) ([]byte, error) {
+	if n < 0 || n > len(d.b) {
+		return nil, errUnexpectedEnd
 	}
-	*v = binary.BigEndian.Uint64(b)
-	return nil
+	v := d.b[:n:n]
+	d.b = d.b[n:]
+	return v, nil
 }
 
-func readUint32(r io.Reader, v *uint32) error {
-	b := make([]byte, 4)
-	if _, err := r.Read(b); err != nil {
-		return err
+func (d *bytesDecoder) uint64() (uint64, error) {
+	b, err := d.next(8)
+	if err != nil {
+		return 0, err
 	}
-	*v = binary.BigEndian.Uint32(b)
-	return nil
+	return binary.BigEndian.Uint64(b), nil
 }
 
-func readBytes(r io.Reader, v *[]byte) error {
-	var n uint32
-	if err := readUint32(r, &n); err != nil {
-		return err
+func (d *bytesDecoder) uint32() (uint32, error) {
+	b, err := d.next(4)
+	if err != nil {
+		return 0, err
 	}
+	return binary.BigEndian.Uint32(b), nil
+}
 
-	*v = make([]byte, n)
-	_, err := io.ReadFull(r, *v)
+// bytes returns bytes prefixed with their uint32 length.
+func (d *bytesDecoder) bytes() ([]byte, error) {
+	n, err := d.uint32()
+	if err != nil {
+		return nil, err
+	}
+	return d.next(int(n))
+}
 
-	return err
+// chunk returns bytes prefixed with their uint64 length.
+func (d *bytesDecoder) chunk() ([]byte, error) {
+	n, err := d.uint64()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.b)) {
+		return nil, errUnexpectedEnd
+	}
+	return d.next(int(n))
 }
@@ -0,0 +1,286 @@
+package yoctodb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestFixedLenSortedSet(t *testing.T) {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data, 3)
+	binary.BigEndian.PutUint32(data[4:], 2)
+	data = append(data, "aabbcc"...)
+
+	set, err := NewFixedLenSortedSet(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []string{"aa", "bb", "cc"} {
+		got, err := set.Get(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("Get(%d) want %q, got %q", i, want, got)
+		}
+		if n := set.Index([]byte(want)); n != i {
+			t.Errorf("Index(%q) want %d, got %d", want, i, n)
+		}
+	}
+	if n := set.Index([]byte("ab")); n != -1 {
+		t.Errorf("Index(%q) want -1, got %d", "ab", n)
+	}
+}
+
+func TestSortedSetSearch(t *testing.T) {
+	set := newTestVarLenSortedSet([][]byte{[]byte("b"), []byte("d"), []byte("f")})
+
+	tests := []struct {
+		Val   string
+		After bool
+		Want  int
+	}{
+		{"a", false, 0},
+		{"b", false, 0},
+		{"b", true, 1},
+		{"c", false, 1},
+		{"c", true, 1},
+		{"f", false, 2},
+		{"f", true, 3},
+		{"g", false, 3},
+	}
+
+	for _, tc := range tests {
+		n, err := sortedSetSearch(set, []byte(tc.Val), tc.After)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != tc.Want {
+			t.Errorf("sortedSetSearch(%q, %v) want %d, got %d", tc.Val, tc.After, tc.Want, n)
+		}
+	}
+}
+
+func TestPrefixSuccessor(t *testing.T) {
+	tests := []struct {
+		Prefix []byte
+		Want   []byte
+	}{
+		{nil, nil},
+		{[]byte("ab"), []byte("ac")},
+		{[]byte{'a', 0xFF}, []byte("b")},
+		{[]byte{0xFF, 0xFF}, nil},
+	}
+
+	for _, tc := range tests {
+		if got := prefixSuccessor(tc.Prefix); !bytes.Equal(got, tc.Want) {
+			t.Errorf("prefixSuccessor(%q) want %q, got %q", tc.Prefix, tc.Want, got)
+		}
+	}
+}
+
+func TestReadDB_Version(t *testing.T) {
+	for _, version := range []int{DBFormatVersion, DBFormatVersion6} {
+		b := NewDBBuilder().WithVersion(version)
+		for _, price := range []string{"100", "200", "300"} {
+			b.Add(NewDocumentBuilder().WithField("price", []byte(price), IndexSortable))
+		}
+
+		var buf bytes.Buffer
+		if _, err := b.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+
+		db, err := ReadVerifyDB(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("(%d) %v", version, err)
+		}
+		if db.Version() != version {
+			t.Fatalf("(%d) Version() want %d, got %d", version, version, db.Version())
+		}
+		if db.DocumentsCount() != 3 {
+			t.Fatalf("(%d) DocumentsCount() want 3, got %d", version, db.DocumentsCount())
+		}
+
+		if version == DBFormatVersion6 {
+			// document count follows magic and version
+			binary.BigEndian.PutUint32(data[8:], 4)
+			if _, err := ReadDB(bytes.NewReader(data)); !errors.Is(err, ErrCorruptedData) {
+				t.Fatalf("(%d) ReadDB() with wrong document count want %v, got %v", version, ErrCorruptedData, err)
+			}
+		}
+	}
+
+	data := make([]byte, 8)
+	copy(data, dbFormatMagic)
+	binary.BigEndian.PutUint32(data[4:], 7)
+	if _, err := ReadDB(bytes.NewReader(data)); err == nil {
+		t.Fatal("ReadDB() of unknown version expected to fail")
+	}
+}
+
+func TestReadDB_Truncated(t *testing.T) {
+	b := NewDBBuilder()
+	for _, price := range []string{"100", "200", "300"} {
+		b.Add(NewDocumentBuilder().
+			WithField("price", []byte(price), IndexSortable).
+			WithField("tag", []byte("tag"+price), IndexFilterable).
+			WithPayload([]byte(price)))
+	}
+
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// cut the data before the digest, so only the segments are broken
+	for n := 8; n < len(data)-dbFormatDigestSize; n++ {
+		truncated := append([]byte(nil), data[:n]...)
+		truncated = append(truncated, data[len(data)-dbFormatDigestSize:]...)
+		db, err := ReadDB(bytes.NewReader(truncated))
+		if err != nil {
+			continue
+		}
+		// data cut at the segments boundary is still valid, but misses the rest of segments
+		if db.Sorter("price") != nil && db.Filter("tag") != nil {
+			t.Fatalf("ReadDB() of data truncated to %d bytes expected to fail", n)
+		}
+	}
+}
+
+func TestListIndexToIndexMultiMap(t *testing.T) {
+	b := NewDBBuilder()
+	for n := 0; n < 300; n++ {
+		b.Add(NewDocumentBuilder().
+			WithField("id", []byte(fmt.Sprintf("id-%03d", n)), IndexFilterable).
+			WithField("parity", []byte(fmt.Sprint(n%2)), IndexFilterable))
+	}
+
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	db, err := ReadVerifyDB(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := db.Filter("id").valToDocs.(*listIndexToIndexMultiMap); !ok {
+		t.Fatalf("expect high-cardinality field to be list-based, got %T", db.Filter("id").valToDocs)
+	}
+	if _, ok := db.Filter("parity").valToDocs.(*bitSetIndexToIndexMultiMap); !ok {
+		t.Fatalf("expect low-cardinality field to be BitSet-based, got %T", db.Filter("parity").valToDocs)
+	}
+
+	ids := queryDocIDs(t, db, &Select{
+		Where: Or(Eq("id", []byte("id-007")), In("id", []byte("id-299"), []byte("id-100"), []byte("id-999"))),
+	})
+	if want := []int{7, 100, 299}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("want %v, got %v", want, ids)
+	}
+
+	ids = queryDocIDs(t, db, &Select{Where: And(Gte("id", []byte("id-290")), Eq("parity", []byte("1")))})
+	if want := []int{291, 293, 295, 297, 299}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("want %v, got %v", want, ids)
+	}
+
+}
+
+func TestIndexToIndexMultiMap_AndCount(t *testing.T) {
+	db := newTestDB(
+		testDoc{"brand": "bmw"},
+		testDoc{"brand": "audi"},
+		testDoc{"brand": "audi"},
+	)
+	valToDocs := db.Filter("brand").valToDocs
+	size := db.DocumentsCount()
+
+	bs := newBitSet(size)
+	bs.Set(1)
+
+	tests := []struct {
+		V    BitSet
+		Want int
+	}{
+		{readOnlyOneBitSet(size), 2},
+		{readOnlyZeroBitSet(size), 0},
+		{bs, 1},
+	}
+	for n, tc := range tests {
+		got, err := valToDocs.andCount(0, tc.V)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.Want {
+			t.Errorf("case %d: andCount() want %d, got %d", n, tc.Want, got)
+		}
+	}
+
+	if _, err := valToDocs.andCount(0, readOnlyOneBitSet(size+64)); err == nil {
+		t.Error("andCount() with BitSet of different size expected to fail")
+	}
+}
+
+func TestFilterableIndex_ValueAt(t *testing.T) {
+	b := NewDBBuilder()
+	for n := 0; n < 300; n++ {
+		b.Add(NewDocumentBuilder().
+			WithField("id", []byte(fmt.Sprintf("id-%03d", n)), IndexFilterable).
+			WithField("size", []byte(fmt.Sprint(n%3)), IndexSortable))
+	}
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	db, err := ReadVerifyDB(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// list-based multimap
+	ids := db.Filter("id")
+	if n := ids.ValuesCount(); n != 300 {
+		t.Fatalf("ValuesCount() want 300, got %d", n)
+	}
+	i := ids.ValueIndex([]byte("id-042"))
+	if i != 42 {
+		t.Fatalf("ValueIndex() want 42, got %d", i)
+	}
+	if val, err := ids.Value(i); err != nil || string(val) != "id-042" {
+		t.Fatalf("Value(%d) want id-042, got %q, %v", i, val, err)
+	}
+	if n, err := ids.DocumentFrequency(i); err != nil || n != 1 {
+		t.Fatalf("DocumentFrequency(%d) want 1, got %d, %v", i, n, err)
+	}
+	if i := ids.ValueIndex([]byte("id-999")); i != -1 {
+		t.Fatalf("ValueIndex() of unknown value want -1, got %d", i)
+	}
+	if _, err := ids.Value(300); err == nil {
+		t.Fatal("Value() out of bounds expected to fail")
+	}
+
+	// BitSet-based multimap of a sortable index
+	sizes := db.Sorter("size")
+	if _, ok := sizes.valToDocs.(*bitSetIndexToIndexMultiMap); !ok {
+		t.Fatalf("expect low-cardinality field to be BitSet-based, got %T", sizes.valToDocs)
+	}
+	for i := 0; i < sizes.ValuesCount(); i++ {
+		n, err := sizes.DocumentFrequency(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 100 {
+			t.Errorf("DocumentFrequency(%d) want 100, got %d", i, n)
+		}
+	}
+	if _, err := sizes.DocumentFrequency(3); err == nil {
+		t.Fatal("DocumentFrequency() out of bounds expected to fail")
+	}
+}
@@ -0,0 +1,41 @@
+package yoctodb
+
+import (
+	"reflect"
+)
+
+// UnmarshalFunc decodes the raw payload of a document into v, e.g. json.Unmarshal.
+type UnmarshalFunc func(data []byte, v interface{}) error
+
+// ScanInto decodes the payload of the current document of docs into a new value of type T.
+//
+// If T is a pointer type, e.g. *pb.Msg, a new value it points to is allocated and unmarshal is called
+// with the pointer itself, not with a pointer to it. Otherwise, unmarshal is called with *T.
+func ScanInto[T any](docs *Documents, unmarshal UnmarshalFunc) (T, error) {
+	var v T
+	var dst interface{} = &v
+	if typ := reflect.TypeOf((*T)(nil)).Elem(); typ.Kind() == reflect.Ptr {
+		v = reflect.New(typ.Elem()).Interface().(T)
+		dst = v
+	}
+	err := docs.Scan(DocumentProcessorFunc(func(_ int, rawData []byte) error {
+		return unmarshal(rawData, dst)
+	}))
+	return v, err
+}
+
+// Collect decodes payloads of all the remaining documents of docs into a slice of values of type T.
+// Documents are closed after.
+func Collect[T any](docs *Documents, unmarshal UnmarshalFunc) ([]T, error) {
+	defer docs.Close()
+
+	var vals []T
+	for docs.Next() {
+		v, err := ScanInto[T](docs, unmarshal)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+	}
+	return vals, docs.Err()
+}
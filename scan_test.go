@@ -0,0 +1,106 @@
+package yoctodb_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/narqo/yoctodb"
+)
+
+func newTestCarsJSONDB(t *testing.T) *yoctodb.DB {
+	t.Helper()
+
+	b := yoctodb.NewDBBuilder()
+	for _, car := range testCars {
+		payload, err := json.Marshal(car)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Add(yoctodb.NewDocumentBuilder().
+			WithField("brand", []byte(car.Brand), yoctodb.IndexFilterable).
+			WithField("price", []byte(car.Price), yoctodb.IndexSortable).
+			WithPayload(payload))
+	}
+	return buildTestDB(t, b)
+}
+
+func TestCollect(t *testing.T) {
+	db := newTestCarsJSONDB(t)
+
+	docs, err := db.Query(context.Background(), &yoctodb.Select{
+		Where:   yoctodb.Eq("brand", []byte("bmw")),
+		OrderBy: yoctodb.Desc("price"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := yoctodb.Collect[testCar](docs, json.Unmarshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []testCar{testCars[3], testCars[1]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if docs.Next() {
+		t.Error("expect documents to be closed")
+	}
+}
+
+func TestScanInto(t *testing.T) {
+	db := newTestCarsJSONDB(t)
+
+	docs, err := db.Query(context.Background(), &yoctodb.Select{Where: yoctodb.Eq("brand", []byte("ford"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer docs.Close()
+
+	if !docs.Next() {
+		t.Fatal("Next() want true")
+	}
+	car, err := yoctodb.ScanInto[*testCar](docs, json.Unmarshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*car, testCars[4]) {
+		t.Errorf("want %v, got %v", testCars[4], *car)
+	}
+
+	unmarshalErr := errors.New("unmarshal failed")
+	_, err = yoctodb.ScanInto[testCar](docs, func([]byte, interface{}) error { return unmarshalErr })
+	if err != unmarshalErr {
+		t.Errorf("ScanInto() want %v, got %v", unmarshalErr, err)
+	}
+}
+
+func TestScanInto_Pointer(t *testing.T) {
+	db := newTestCarsJSONDB(t)
+
+	docs, err := db.Query(context.Background(), &yoctodb.Select{Where: yoctodb.Eq("brand", []byte("bmw"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a pointer type is passed to unmarshal as is, the way decoders like proto.Unmarshal expect
+	unmarshal := func(data []byte, v interface{}) error {
+		car, ok := v.(*testCar)
+		if !ok {
+			return fmt.Errorf("want *testCar, got %T", v)
+		}
+		return json.Unmarshal(data, car)
+	}
+	cars, err := yoctodb.Collect[*testCar](docs, unmarshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cars) != 2 || cars[0] == cars[1] {
+		t.Fatalf("want 2 distinct cars, got %v", cars)
+	}
+	if !reflect.DeepEqual(*cars[0], testCars[1]) || !reflect.DeepEqual(*cars[1], testCars[3]) {
+		t.Errorf("want %v, got %v, %v", []testCar{testCars[1], testCars[3]}, *cars[0], *cars[1])
+	}
+}
@@ -0,0 +1,135 @@
+package yoctodb
+
+import (
+	"sort"
+)
+
+// Schema describes the fields and the payload of the database.
+type Schema struct {
+	// Version is the format version of the database.
+	Version int
+	// DocumentsCount is the number of documents in the database.
+	DocumentsCount int
+	// Payload is the type of the payload segment: PayloadFull or PayloadNone.
+	Payload uint32
+	// Fields are sorted by name.
+	Fields []FieldSchema
+}
+
+// FieldSchema describes the indexes of a field.
+type FieldSchema struct {
+	Name       string
+	Filterable bool
+	Sortable   bool
+	// Segments describe the index segments of the field. A full index is a single segment,
+	// while separate filterable and sortable indexes are two.
+	Segments []SegmentSchema
+}
+
+// SegmentSchema describes an index segment of a field.
+type SegmentSchema struct {
+	// Type is the type of the segment, e.g. FixedLenFilterableIndex.
+	Type uint32
+	// ElemSize is the size of every value in bytes. It is zero for values of variable length.
+	ElemSize int
+	// Cardinality is the number of distinct values in the segment.
+	Cardinality int
+	// MultiMap is the kind of the mapping from values to documents: "bitset" or "list".
+	MultiMap string
+	// Size is the size of the segment in bytes.
+	Size int
+}
+
+// Schema returns the description of the database. Segments of the database loaded with LoadDB
+// are read, if they were not used yet.
+func (db *DB) Schema() (*Schema, error) {
+	schema := &Schema{
+		Version:        db.version,
+		DocumentsCount: db.DocumentsCount(),
+		Payload:        payloadType(db.payload),
+	}
+
+	for _, name := range db.fieldNames() {
+		f, err := db.filter(name)
+		if err != nil {
+			return nil, err
+		}
+		s, err := db.sorter(name)
+		if err != nil {
+			return nil, err
+		}
+
+		field := FieldSchema{Name: name}
+		if f != nil {
+			field.Filterable = true
+			field.Segments = append(field.Segments, segmentSchema(f))
+		}
+		if s != nil {
+			field.Sortable = true
+			// both parts of a full index are read from the same segment
+			if f != &s.FilterableIndex {
+				field.Segments = append(field.Segments, segmentSchema(&s.FilterableIndex))
+			}
+		}
+		schema.Fields = append(schema.Fields, field)
+	}
+
+	return schema, nil
+}
+
+// fieldNames returns sorted names of all indexed fields.
+func (db *DB) fieldNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for name := range db.filters {
+		add(name)
+	}
+	for name := range db.sorters {
+		add(name)
+	}
+	if db.lazy != nil {
+		for name := range db.lazy.filters {
+			add(name)
+		}
+		for name := range db.lazy.sorters {
+			add(name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func segmentSchema(index *FilterableIndex) SegmentSchema {
+	s := SegmentSchema{
+		Type:        index.typ,
+		Cardinality: index.vals.Size(),
+		Size:        index.size,
+	}
+	if vals, ok := index.vals.(*fixedLenSortedSet); ok {
+		s.ElemSize = vals.elemSize
+	}
+	switch index.valToDocs.(type) {
+	case *bitSetIndexToIndexMultiMap:
+		s.MultiMap = "bitset"
+	case *listIndexToIndexMultiMap:
+		s.MultiMap = "list"
+	}
+	return s
+}
+
+func payloadType(p payloadSegment) uint32 {
+	switch p := p.(type) {
+	case *EmptyPayload:
+		return PayloadNone
+	case *lazyPayload:
+		return p.segment.typ
+	default:
+		return PayloadFull
+	}
+}
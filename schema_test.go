@@ -0,0 +1,111 @@
+package yoctodb_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/narqo/yoctodb"
+)
+
+func TestDB_Schema(t *testing.T) {
+	data := newTestCarsData(t)
+
+	db, err := yoctodb.ReadDB(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := db.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if schema.Version != yoctodb.DBFormatVersion6 || schema.DocumentsCount != len(testCars) || schema.Payload != yoctodb.PayloadFull {
+		t.Fatalf("unexpected schema %+v", schema)
+	}
+
+	var names []string
+	for _, f := range schema.Fields {
+		names = append(names, f.Name)
+		if len(f.Segments) != 1 || f.Segments[0].Size <= 0 || f.Segments[0].MultiMap == "" {
+			t.Errorf("unexpected field %+v", f)
+		}
+	}
+	if want := []string{"brand", "color", "price", "tag"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("want fields %v, got %v", want, names)
+	}
+
+	brand, color, price := schema.Fields[0], schema.Fields[1], schema.Fields[2]
+	if s := brand.Segments[0]; !brand.Filterable || brand.Sortable || s.ElemSize != 0 || s.Cardinality != 3 ||
+		s.Type != yoctodb.VarLenFilterableIndex {
+		t.Errorf("unexpected brand field %+v", brand)
+	}
+	if s := color.Segments[0]; s.ElemSize != 6 || s.Cardinality != 3 || s.Type != yoctodb.FixedLenFilterableIndex {
+		t.Errorf("unexpected color field %+v", color)
+	}
+	if s := price.Segments[0]; price.Filterable || !price.Sortable || s.ElemSize != 3 || s.Cardinality != 3 ||
+		s.Type != yoctodb.FixedLenSortableIndex {
+		t.Errorf("unexpected price field %+v", price)
+	}
+
+	lazyDB, err := yoctodb.LoadDB(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lazySchema, err := lazyDB.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lazySchema, schema) {
+		t.Errorf("want lazy schema %+v, got %+v", schema, lazySchema)
+	}
+}
+
+func TestDB_SchemaIndexFull(t *testing.T) {
+	b := yoctodb.NewDBBuilder().WithoutPayload()
+	for _, car := range testCars {
+		b.Add(yoctodb.NewDocumentBuilder().WithField("brand", []byte(car.Brand), yoctodb.IndexFull))
+	}
+	db := buildTestDB(t, b)
+
+	schema, err := db.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Payload != yoctodb.PayloadNone || len(schema.Fields) != 1 {
+		t.Fatalf("unexpected schema %+v", schema)
+	}
+	brand := schema.Fields[0]
+	if !brand.Filterable || !brand.Sortable || len(brand.Segments) != 1 || brand.Segments[0].Type != yoctodb.VarLenFullIndexSegment {
+		t.Errorf("unexpected brand field %+v", brand)
+	}
+}
+
+func TestDB_SchemaSeparateSegments(t *testing.T) {
+	b := yoctodb.NewDBBuilder().WithoutPayload()
+	for _, price := range []string{"100", "2000", "100"} {
+		b.Add(yoctodb.NewDocumentBuilder().
+			WithField("price", []byte(price), yoctodb.IndexFilterable).
+			WithField("price", []byte(price), yoctodb.IndexSortable))
+	}
+	db := buildTestDB(t, b)
+
+	schema, err := db.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.DocumentsCount != 3 || len(schema.Fields) != 1 {
+		t.Fatalf("unexpected schema %+v", schema)
+	}
+	price := schema.Fields[0]
+	if price.Name != "price" || !price.Filterable || !price.Sortable || len(price.Segments) != 2 {
+		t.Fatalf("unexpected price field %+v", price)
+	}
+	filterable, sortable := price.Segments[0], price.Segments[1]
+	if filterable.Type != yoctodb.VarLenFilterableIndex || filterable.Cardinality != 2 || filterable.Size <= 0 {
+		t.Errorf("unexpected filterable segment %+v", filterable)
+	}
+	if sortable.Type != yoctodb.VarLenSortableIndex || sortable.Cardinality != 2 || sortable.Size <= filterable.Size {
+		t.Errorf("unexpected sortable segment %+v", sortable)
+	}
+}
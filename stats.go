@@ -0,0 +1,184 @@
+package yoctodb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/narqo/yoctodb/codec"
+)
+
+// NumericDecoder decodes a value of a field into a number.
+type NumericDecoder func(b []byte) (float64, error)
+
+// Int64Numeric decodes values encoded with codec.EncodeInt64.
+func Int64Numeric(b []byte) (float64, error) {
+	v, err := codec.DecodeInt64(b)
+	return float64(v), err
+}
+
+// Uint64Numeric decodes values encoded with codec.EncodeUint64.
+func Uint64Numeric(b []byte) (float64, error) {
+	v, err := codec.DecodeUint64(b)
+	return float64(v), err
+}
+
+// Float64Numeric decodes values encoded with codec.EncodeFloat64.
+func Float64Numeric(b []byte) (float64, error) {
+	return codec.DecodeFloat64(b)
+}
+
+// statsCtxCheckInterval is the number of documents counted between checks of the context.
+const statsCtxCheckInterval = 1024
+
+// FieldStats are statistics of the values of a sortable field over the documents matching a query.
+type FieldStats struct {
+	// Count is the number of matching documents.
+	Count int
+	// Min and Max are the least and the greatest values of the field. Both are nil, if no documents match.
+	Min []byte
+	Max []byte
+
+	vals SortedSet
+	// counts holds the number of matching documents per each value index.
+	counts []int
+}
+
+// Stats collects statistics of the values of the field over the documents matching the query.
+// Values are taken from the sortable index of the field, without reading payloads.
+// Limit and offset of the query are ignored.
+func (db *DB) Stats(ctx context.Context, q Query, field string) (*FieldStats, error) {
+	index, err := db.sorter(field)
+	if err != nil {
+		return nil, err
+	}
+	if index == nil {
+		return nil, fmt.Errorf("no sortable index for field %q", field)
+	}
+
+	stats := &FieldStats{
+		vals:   index.vals,
+		counts: make([]int, index.vals.Size()),
+	}
+
+	bs, err := q.filteredUnlimited(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return stats, nil
+	}
+	defer releaseBitSet(bs)
+
+	for i, n := 0, bs.NextSet(0); n >= 0; i, n = i+1, bs.NextSet(n+1) {
+		if i%statsCtxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		v, err := index.docToVals.Get(n)
+		if err != nil {
+			return nil, fmt.Errorf("could not get value of field %q for document %d: %v", field, n, err)
+		}
+		if v < 0 || v >= len(stats.counts) {
+			return nil, errOutOfBounds
+		}
+		stats.counts[v]++
+		stats.Count++
+	}
+
+	if stats.Count == 0 {
+		return stats, nil
+	}
+	if stats.Min, err = stats.Percentile(0); err != nil {
+		return nil, err
+	}
+	if stats.Max, err = stats.Percentile(100); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Percentile returns the value, which p percent of matching documents have less than or equal to,
+// using the nearest-rank method. p must be between 0 and 100. It returns nil, if no documents match.
+func (s *FieldStats) Percentile(p float64) ([]byte, error) {
+	if p < 0 || p > 100 || math.IsNaN(p) {
+		return nil, fmt.Errorf("percentile %v out of range [0, 100]", p)
+	}
+	if s.Count == 0 {
+		return nil, nil
+	}
+	rank := int(math.Ceil(p / 100 * float64(s.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+	for i, count := range s.counts {
+		rank -= count
+		if rank <= 0 {
+			return s.vals.Get(i)
+		}
+	}
+	return nil, errOutOfBounds
+}
+
+// Sum returns the sum of the values of matching documents, decoded with dec.
+func (s *FieldStats) Sum(dec NumericDecoder) (float64, error) {
+	var sum float64
+	err := s.eachValue(dec, func(v float64, count int) {
+		sum += v * float64(count)
+	})
+	return sum, err
+}
+
+// Avg returns the average of the values of matching documents, decoded with dec.
+// It returns NaN, if no documents match.
+func (s *FieldStats) Avg(dec NumericDecoder) (float64, error) {
+	if s.Count == 0 {
+		return math.NaN(), nil
+	}
+	sum, err := s.Sum(dec)
+	if err != nil {
+		return 0, err
+	}
+	return sum / float64(s.Count), nil
+}
+
+// Histogram counts matching documents per buckets of values, decoded with dec. The bounds must be sorted
+// in ascending order. The bucket i counts the values v, such that bounds[i-1] <= v < bounds[i],
+// so there are len(bounds)+1 buckets, and the first and the last ones are unbounded.
+func (s *FieldStats) Histogram(dec NumericDecoder, bounds []float64) ([]int, error) {
+	if !sort.Float64sAreSorted(bounds) {
+		return nil, fmt.Errorf("histogram bounds are not sorted: %v", bounds)
+	}
+	buckets := make([]int, len(bounds)+1)
+	err := s.eachValue(dec, func(v float64, count int) {
+		i := sort.Search(len(bounds), func(i int) bool {
+			return bounds[i] > v
+		})
+		buckets[i] += count
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// eachValue calls fn for every value of matching documents and the number of documents with it.
+func (s *FieldStats) eachValue(dec NumericDecoder, fn func(v float64, count int)) error {
+	for i, count := range s.counts {
+		if count == 0 {
+			continue
+		}
+		raw, err := s.vals.Get(i)
+		if err != nil {
+			return err
+		}
+		v, err := dec(raw)
+		if err != nil {
+			return err
+		}
+		fn(v, count)
+	}
+	return nil
+}
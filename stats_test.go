@@ -0,0 +1,127 @@
+package yoctodb
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/narqo/yoctodb/codec"
+)
+
+func newTestPricesDB(t *testing.T, prices []int64, brands []string) *DB {
+	t.Helper()
+
+	b := NewDBBuilder()
+	for n, price := range prices {
+		b.Add(NewDocumentBuilder().
+			WithField("price", codec.EncodeInt64(price), IndexSortable).
+			WithField("brand", []byte(brands[n]), IndexFilterable))
+	}
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	db, err := ReadVerifyDB(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func decodeTestInt64(t *testing.T, b []byte) int64 {
+	t.Helper()
+
+	v, err := codec.DecodeInt64(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestDB_Stats(t *testing.T) {
+	db := newTestPricesDB(t,
+		[]int64{300, -50, 100, 300, 200, 1000},
+		[]string{"audi", "bmw", "audi", "bmw", "ford", "bmw"},
+	)
+	ctx := context.Background()
+
+	stats, err := db.Stats(ctx, &Select{Where: In("brand", []byte("audi"), []byte("bmw"))}, "price")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Count != 5 {
+		t.Fatalf("Count want 5, got %d", stats.Count)
+	}
+	if min := decodeTestInt64(t, stats.Min); min != -50 {
+		t.Errorf("Min want -50, got %d", min)
+	}
+	if max := decodeTestInt64(t, stats.Max); max != 1000 {
+		t.Errorf("Max want 1000, got %d", max)
+	}
+
+	for p, want := range map[float64]int64{10: -50, 40: 100, 50: 300, 80: 300, 90: 1000} {
+		v, err := stats.Percentile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := decodeTestInt64(t, v); got != want {
+			t.Errorf("Percentile(%v) want %d, got %d", p, want, got)
+		}
+	}
+	if _, err := stats.Percentile(101); err == nil {
+		t.Error("Percentile(101) expected to fail")
+	}
+
+	sum, err := stats.Sum(Int64Numeric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != 1650 {
+		t.Errorf("Sum want 1650, got %v", sum)
+	}
+	avg, err := stats.Avg(Int64Numeric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if avg != 330 {
+		t.Errorf("Avg want 330, got %v", avg)
+	}
+
+	hist, err := stats.Histogram(Int64Numeric, []float64{0, 100, 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 0, 3, 1}; !reflect.DeepEqual(hist, want) {
+		t.Errorf("Histogram want %v, got %v", want, hist)
+	}
+	if _, err := stats.Histogram(Int64Numeric, []float64{100, 0}); err == nil {
+		t.Error("Histogram() with unsorted bounds expected to fail")
+	}
+	if _, err := stats.Sum(Float64Numeric); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stats.Sum(func([]byte) (float64, error) { return 0, errOutOfBounds }); err != errOutOfBounds {
+		t.Errorf("Sum() want decoder error, got %v", err)
+	}
+}
+
+func TestDB_StatsEmpty(t *testing.T) {
+	db := newTestPricesDB(t, []int64{300, 100}, []string{"audi", "bmw"})
+
+	stats, err := db.Stats(context.Background(), &Select{Where: Eq("brand", []byte("ford"))}, "price")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Count != 0 || stats.Min != nil || stats.Max != nil {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if avg, err := stats.Avg(Int64Numeric); err != nil || !math.IsNaN(avg) {
+		t.Errorf("Avg() want NaN, got %v, %v", avg, err)
+	}
+
+	if _, err := db.Stats(context.Background(), &Select{}, "brand"); err == nil {
+		t.Error("Stats() of not sortable field expected to fail")
+	}
+}
@@ -0,0 +1,94 @@
+package yoctodb
+
+import (
+	"time"
+
+	"github.com/narqo/yoctodb/codec"
+)
+
+// EqInt64 matches documents with values of the field equal to val, encoded with codec.EncodeInt64.
+func EqInt64(name string, val int64) Condition {
+	return Eq(name, codec.EncodeInt64(val))
+}
+
+// EqUint64 matches documents with values of the field equal to val, encoded with codec.EncodeUint64.
+func EqUint64(name string, val uint64) Condition {
+	return Eq(name, codec.EncodeUint64(val))
+}
+
+// EqFloat64 matches documents with values of the field equal to val, encoded with codec.EncodeFloat64.
+func EqFloat64(name string, val float64) Condition {
+	return Eq(name, codec.EncodeFloat64(val))
+}
+
+// EqInt32 matches documents with values of the field equal to val, encoded with codec.EncodeInt32.
+func EqInt32(name string, val int32) Condition {
+	return Eq(name, codec.EncodeInt32(val))
+}
+
+// EqUint32 matches documents with values of the field equal to val, encoded with codec.EncodeUint32.
+func EqUint32(name string, val uint32) Condition {
+	return Eq(name, codec.EncodeUint32(val))
+}
+
+// EqFloat32 matches documents with values of the field equal to val, encoded with codec.EncodeFloat32.
+func EqFloat32(name string, val float32) Condition {
+	return Eq(name, codec.EncodeFloat32(val))
+}
+
+// EqBool matches documents with values of the field equal to val, encoded with codec.EncodeBool.
+func EqBool(name string, val bool) Condition {
+	return Eq(name, codec.EncodeBool(val))
+}
+
+// EqTime matches documents with values of the field equal to val, encoded with codec.EncodeTime.
+func EqTime(name string, val time.Time) Condition {
+	return Eq(name, codec.EncodeTime(val))
+}
+
+// EqUUID matches documents with values of the field equal to val, encoded with codec.EncodeUUID.
+func EqUUID(name string, val [16]byte) Condition {
+	return Eq(name, codec.EncodeUUID(val))
+}
+
+// RangeInt64 matches documents with values of the field between from and to inclusive,
+// encoded with codec.EncodeInt64.
+func RangeInt64(name string, from, to int64) Condition {
+	return Between(name, codec.EncodeInt64(from), true, codec.EncodeInt64(to), true)
+}
+
+// RangeUint64 matches documents with values of the field between from and to inclusive,
+// encoded with codec.EncodeUint64.
+func RangeUint64(name string, from, to uint64) Condition {
+	return Between(name, codec.EncodeUint64(from), true, codec.EncodeUint64(to), true)
+}
+
+// RangeFloat64 matches documents with values of the field between from and to inclusive,
+// encoded with codec.EncodeFloat64.
+func RangeFloat64(name string, from, to float64) Condition {
+	return Between(name, codec.EncodeFloat64(from), true, codec.EncodeFloat64(to), true)
+}
+
+// RangeInt32 matches documents with values of the field between from and to inclusive,
+// encoded with codec.EncodeInt32.
+func RangeInt32(name string, from, to int32) Condition {
+	return Between(name, codec.EncodeInt32(from), true, codec.EncodeInt32(to), true)
+}
+
+// RangeUint32 matches documents with values of the field between from and to inclusive,
+// encoded with codec.EncodeUint32.
+func RangeUint32(name string, from, to uint32) Condition {
+	return Between(name, codec.EncodeUint32(from), true, codec.EncodeUint32(to), true)
+}
+
+// RangeFloat32 matches documents with values of the field between from and to inclusive,
+// encoded with codec.EncodeFloat32.
+func RangeFloat32(name string, from, to float32) Condition {
+	return Between(name, codec.EncodeFloat32(from), true, codec.EncodeFloat32(to), true)
+}
+
+// RangeTime matches documents with values of the field between from and to inclusive,
+// encoded with codec.EncodeTime.
+func RangeTime(name string, from, to time.Time) Condition {
+	return Between(name, codec.EncodeTime(from), true, codec.EncodeTime(to), true)
+}
@@ -0,0 +1,59 @@
+package yoctodb_test
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/narqo/yoctodb"
+	"github.com/narqo/yoctodb/codec"
+)
+
+func TestTypedConditions(t *testing.T) {
+	prices := []float64{-1.5, 100, 99.99, 1e6, 0}
+	years := []int64{2015, -300, 2017, 2016, 2015}
+	mileages := []uint32{0, 12000, 150000, 30000, 12000}
+	ratings := []float32{4.5, -1, 3.25, 5, 4.5}
+	b := yoctodb.NewDBBuilder()
+	for n := range prices {
+		b.Add(yoctodb.NewDocumentBuilder().
+			WithField("price", codec.EncodeFloat64(prices[n]), yoctodb.IndexSortable).
+			WithField("year", codec.EncodeInt64(years[n]), yoctodb.IndexFilterable).
+			WithField("used", codec.EncodeBool(n%2 == 0), yoctodb.IndexFilterable).
+			WithField("mileage", codec.EncodeUint32(mileages[n]), yoctodb.IndexFilterable).
+			WithField("rating", codec.EncodeFloat32(ratings[n]), yoctodb.IndexSortable).
+			WithField("week", codec.EncodeInt32(int32(years[n]-2016)), yoctodb.IndexFilterable).
+			WithPayload([]byte(strconv.Itoa(n))))
+	}
+	db := buildTestDB(t, b)
+
+	tests := []struct {
+		Query yoctodb.Query
+		Want  []string
+	}{
+		{&yoctodb.Select{Where: yoctodb.EqInt64("year", 2015)}, []string{"0", "4"}},
+		{&yoctodb.Select{Where: yoctodb.EqBool("used", false)}, []string{"1", "3"}},
+		{&yoctodb.Select{Where: yoctodb.EqFloat64("price", 99.99)}, []string{"2"}},
+		{&yoctodb.Select{Where: yoctodb.RangeInt64("year", -1000, 2015)}, []string{"0", "1", "4"}},
+		{
+			&yoctodb.Select{Where: yoctodb.RangeFloat64("price", -10, 100), OrderBy: yoctodb.Asc("price")},
+			[]string{"0", "4", "2", "1"},
+		},
+		{&yoctodb.Select{Where: yoctodb.EqInt32("week", -1)}, []string{"0", "4"}},
+		{&yoctodb.Select{Where: yoctodb.EqUint32("mileage", 12000)}, []string{"1", "4"}},
+		{&yoctodb.Select{Where: yoctodb.EqFloat32("rating", 3.25)}, []string{"2"}},
+		{&yoctodb.Select{Where: yoctodb.RangeInt32("week", -3000, 0)}, []string{"0", "1", "3", "4"}},
+		{&yoctodb.Select{Where: yoctodb.RangeUint32("mileage", 1, 100000)}, []string{"1", "3", "4"}},
+		{
+			&yoctodb.Select{Where: yoctodb.RangeFloat32("rating", -2, 4.5), OrderBy: yoctodb.Asc("rating")},
+			[]string{"1", "2", "0", "4"},
+		},
+	}
+
+	for n, tc := range tests {
+		got := queryPayloads(t, db, tc.Query)
+		if !reflect.DeepEqual(got, tc.Want) {
+			t.Errorf("case %d: want %v, got %v", n, tc.Want, got)
+		}
+	}
+}